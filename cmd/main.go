@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"os"
+	"os/exec"
 	"strings"
 
 	"github.com/curioswitch/protog/internal/cmd"
@@ -14,5 +16,12 @@ func main() {
 			env[key] = value
 		}
 	}
-	_ = cmd.Run(os.Args[1:], env)
+	err := cmd.Run(os.Args[1:], env)
+	// Exit with the status of a tool that failed, e.g. for protog exec in scripts.
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	} else if err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,181 @@
+package protog
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/curioswitch/protog/internal/cmd"
+	"github.com/curioswitch/protog/internal/tools"
+)
+
+// GenerateRequest describes code to generate with protoc.
+type GenerateRequest struct {
+	// Inputs are the proto files to compile.
+	Inputs []string
+	// ProtoPaths are directories to search for imports, as with --proto_path. Imports are downloaded as needed
+	// the same as when running protog from the command line.
+	ProtoPaths []string
+	// Outputs are the plugins to run and where to write what they generate.
+	Outputs []Output
+}
+
+// Output is a plugin to run and where to write what it generates.
+type Output struct {
+	// Plugin is the name of the plugin as in its protoc flag, e.g. go for --go_out or grpc_python for
+	// --grpc_python_out.
+	Plugin string
+	// Out is the directory to write generated files to.
+	Out string
+	// Options are passed to the plugin, as with --go_opt for the go plugin.
+	Options []string
+}
+
+// GenerateResult describes code generated by Generate.
+type GenerateResult struct {
+	// Files are the files generated by each plugin, keyed by Output.Plugin, with paths including the Out directory.
+	Files map[string][]string
+	// Versions are the resolved versions of the tools used, including protoc.
+	Versions ToolVersions
+	// Timings are the time spent in each step of generating.
+	Timings Timings
+}
+
+// Timings are the time spent in each step of generating.
+type Timings struct {
+	// Tools is the time spent resolving and installing protoc and plugins.
+	Tools time.Duration
+	// Includes is the time spent downloading imported protos.
+	Includes time.Duration
+	// Protoc is the time spent running protoc and plugins.
+	Protoc time.Duration
+	// Total is the time spent in Generate.
+	Total time.Duration
+}
+
+// Generate runs protoc to generate code as described by req, fetching tools and imports the same as Run.
+//
+// Plugins each write to an archive that is then extracted to their Out directory, so files generated by each can
+// be reported. This means plugins cannot use insertion points into files generated by other plugins.
+func Generate(ctx context.Context, req GenerateRequest, config Config) (*GenerateResult, error) {
+	env, err := configEnv(config)
+	if err != nil {
+		return nil, err
+	}
+	return generate(ctx, req, nil, env)
+}
+
+// generate runs protoc for req with tools from m, or a ToolManager configured by env if nil.
+func generate(ctx context.Context, req GenerateRequest, m *tools.ToolManager, env map[string]string) (*GenerateResult, error) {
+	start := time.Now()
+
+	if len(req.Inputs) == 0 {
+		return nil, errors.New("no inputs to generate from")
+	}
+	if len(req.Outputs) == 0 {
+		return nil, errors.New("no outputs to generate")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "protog-generate-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var args []string
+	for _, p := range req.ProtoPaths {
+		args = append(args, "--proto_path="+p)
+	}
+	archives := make([]string, len(req.Outputs))
+	for i, o := range req.Outputs {
+		if o.Plugin == "" || o.Out == "" {
+			return nil, fmt.Errorf("output %d must have a plugin and out directory", i)
+		}
+		archives[i] = filepath.Join(tmpDir, fmt.Sprintf("%d.zip", i))
+		args = append(args, fmt.Sprintf("--%s_out=%s", o.Plugin, archives[i]))
+		for _, opt := range o.Options {
+			args = append(args, fmt.Sprintf("--%s_opt=%s", o.Plugin, opt))
+		}
+	}
+	args = append(args, req.Inputs...)
+
+	protocRes, err := cmd.Execute(ctx, m, args, env)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &GenerateResult{
+		Files:    map[string][]string{},
+		Versions: protocRes.Versions,
+		Timings: Timings{
+			Tools:    protocRes.ToolsDuration,
+			Includes: protocRes.IncludesDuration,
+			Protoc:   protocRes.ProtocDuration,
+		},
+	}
+	for i, o := range req.Outputs {
+		files, err := extractArchive(archives[i], o.Out)
+		if err != nil {
+			return nil, fmt.Errorf("writing output of %s: %w", o.Plugin, err)
+		}
+		res.Files[o.Plugin] = append(res.Files[o.Plugin], files...)
+	}
+	res.Timings.Total = time.Since(start)
+
+	return res, nil
+}
+
+// extractArchive extracts the files in a zip written by protoc into dir, returning their paths.
+func extractArchive(archive string, dir string) ([]string, error) {
+	r, err := zip.OpenReader(archive)
+	if errors.Is(err, os.ErrNotExist) {
+		// Nothing was generated.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var files []string
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("generated file %s is outside of %s", f.Name, dir)
+		}
+		if err := extractFile(f, path); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+func extractFile(f *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
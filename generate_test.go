@@ -0,0 +1,94 @@
+package protog
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	goDir := filepath.Join(dir, "go")
+
+	res, err := Generate(context.Background(), GenerateRequest{
+		Inputs:     []string{filepath.Join("testdata", "helloworld.proto")},
+		ProtoPaths: []string{"testdata"},
+		Outputs: []Output{
+			{Plugin: "go", Out: goDir, Options: []string{"paths=source_relative"}},
+			{Plugin: "go-grpc", Out: goDir, Options: []string{"paths=source_relative"}},
+		},
+	}, Config{})
+	require.NoError(t, err)
+
+	require.Equal(t, map[string][]string{
+		"go":      {filepath.Join(goDir, "helloworld.pb.go")},
+		"go-grpc": {filepath.Join(goDir, "helloworld_grpc.pb.go")},
+	}, res.Files)
+	for _, files := range res.Files {
+		for _, f := range files {
+			require.FileExists(t, f)
+		}
+	}
+	require.Contains(t, res.Versions, "protoc")
+	require.Contains(t, res.Versions, "protoc-gen-go")
+	require.Contains(t, res.Versions, "protoc-gen-go-grpc")
+	require.GreaterOrEqual(t, res.Timings.Total, res.Timings.Protoc)
+}
+
+func TestExtractArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "out.zip")
+	writeZip := func(names ...string) {
+		f, err := os.Create(archive)
+		require.NoError(t, err)
+		w := zip.NewWriter(f)
+		for _, name := range names {
+			fw, err := w.Create(name)
+			require.NoError(t, err)
+			_, err = fw.Write([]byte("// " + name))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		require.NoError(t, f.Close())
+	}
+
+	out := filepath.Join(dir, "out")
+	writeZip("acme/v1/service.pb.go", "acme/v1/service_grpc.pb.go")
+	files, err := extractArchive(archive, out)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(out, "acme", "v1", "service.pb.go"),
+		filepath.Join(out, "acme", "v1", "service_grpc.pb.go"),
+	}, files)
+	b, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.Equal(t, "// acme/v1/service.pb.go", string(b))
+
+	writeZip("../escape.go")
+	_, err = extractArchive(archive, out)
+	require.ErrorContains(t, err, "outside of")
+
+	files, err = extractArchive(filepath.Join(dir, "missing.zip"), out)
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	// The current directory, the usual output directory with paths=source_relative.
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(out))
+	t.Cleanup(func() {
+		require.NoError(t, os.Chdir(wd))
+	})
+	writeZip("acme/v1/service.pb.go")
+	files, err = extractArchive(archive, ".")
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join("acme", "v1", "service.pb.go")}, files)
+
+	writeZip("../escape.go")
+	_, err = extractArchive(archive, ".")
+	require.ErrorContains(t, err, "outside of")
+}
@@ -1,13 +1,38 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/curioswitch/protog/internal/proto"
 	"github.com/curioswitch/protog/internal/tools"
 	"github.com/spf13/cobra"
 )
 
 func Run(args []string, env map[string]string) error {
+	_, err := Execute(context.Background(), nil, args, env)
+	return err
+}
+
+// Execute runs protog like Run, returning the result of running protoc. The result is empty if a subcommand is
+// run instead. Tools are installed with m if not nil, otherwise with a ToolManager configured by env.
+func Execute(ctx context.Context, m *tools.ToolManager, args []string, env map[string]string) (tools.ProtocResult, error) {
+	var result tools.ProtocResult
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return result, err
+	}
+	pins, err := findPins(env, cwd)
+	if err != nil {
+		return result, err
+	}
+	env = withPins(env, pins)
+
 	var connectESOut string
 	var connectGoOut string
 	var cppOut string
@@ -41,7 +66,14 @@ func Run(args []string, env map[string]string) error {
 	var improbableTSOut string
 	var validateOut string
 
+	var refresh bool
+	var verbose bool
+
 	cmd := &cobra.Command{
+		Use:   "protog [flags] PROTO_FILES",
+		Short: "A drop-in replacement for protoc that manages dependencies",
+		// Positional arguments are proto files rather than subcommands.
+		Args: cobra.ArbitraryArgs,
 		FParseErrWhitelist: cobra.FParseErrWhitelist{
 			UnknownFlags: true,
 		},
@@ -85,64 +117,48 @@ func Run(args []string, env map[string]string) error {
 				}
 			}
 
-			m, err := tools.NewToolManager(
-				tools.Config{
-					Versions: tools.Versions{
-						Go:                      env["GO_VERSION"],
-						NodeJS:                  env["NODEJS_VERSION"],
-						Protoc:                  env["PROTOC_VERSION"],
-						ProtocGenConnectES:      env["PROTOC_GEN_CONNECT_ES_VERSION"],
-						ProtocGenConnectGo:      env["PROTOC_GEN_CONNECT_GO_VERSION"],
-						ProtocGenDoc:            env["PROTOC_GEN_DOC_VERSION"],
-						ProtocGenDocs:           env["PROTOC_GEN_DOCS_VERSION"],
-						ProtocGenES:             env["PROTOC_GEN_ES_VERSION"],
-						ProtocGenGo:             env["PROTOC_GEN_GO_VERSION"],
-						ProtocGenGolangDeepCopy: env["PROTOC_GEN_GOLANG_DEEPCOPY_VERSION"],
-						ProtocGenGolangJSONShim: env["PROTOC_GEN_GOLANG_JSONSHIM_VERSION"],
-						ProtocGenGogoFast:       env["PROTOC_GEN_GOGO_FAST_VERSION"],
-						ProtocGenGoGRPC:         env["PROTOC_GEN_GO_GRPC_VERSION"],
-						ProtocGenGRPC:           env["PROTOC_GEN_GRPC_VERSION"],
-						ProtocGenGRPCGateway:    env["PROTOC_GEN_GRPC_GATEWAY_VERSION"],
-						ProtocGenGRPCWeb:        env["PROTOC_GEN_GRPC_WEB_VERSION"],
-						ProtocGenGRPCJava:       env["PROTOC_GEN_GRPC_JAVA_VERSION"],
-						ProtocGenJSONSchema:     env["PROTOC_GEN_JSONSCHEMA_VERSION"],
-						ProtocGenTS:             env["PROTOC_GEN_TS_VERSION"],
-						ProtocGenValidate:       env["PROTOC_GEN_VALIDATE_VERSION"],
-						ProtocTSGen:             env["PROTOC_TS_GEN_VERSION"],
-					},
-					Protoc: tools.ProtocConfig{
-						ConnectES:      connectESOut != "",
-						ConnectGo:      connectGoOut != "",
-						CppGRPC:        cppGRPCOut != "",
-						CSharpGRPC:     cSharpGRPCOut != "",
-						Doc:            docOut != "",
-						Docs:           docsOut != "",
-						ES:             esOut != "",
-						Go:             goOut != "",
-						GogoFast:       gogoFastOut != "",
-						GolangDeepCopy: golangDeepCopyOut != "",
-						GolangJSONShim: golangJsonShimOut != "",
-						GoGRPC:         goGrpcOut != "",
-						GRPCGateway:    grpcGatewayOut != "",
-						GRPCWeb:        grpcWebOut != "",
-						ImprobableTS:   improbableTSOut != "",
-						JavaGRPC:       javaGrpcOut != "",
-						JavascriptGRPC: jsGRPCOut != "",
-						JSONSchema:     jsonSchemaOut != "",
-						ObjectiveCGRPC: objcGRPCOut != "",
-						PHPGRPC:        phpGRPCOut != "",
-						PythonGRPC:     pythonGRPCOut != "",
-						RubyGRPC:       rubyGRPCOut != "",
-						TS:             tsOut != "",
-						Validate:       validateOut != "",
-					},
-				},
-			)
-			if err != nil {
-				return err
+			protocConfig := tools.ProtocConfig{
+				ConnectES:      connectESOut != "",
+				ConnectGo:      connectGoOut != "",
+				CppGRPC:        cppGRPCOut != "",
+				CSharpGRPC:     cSharpGRPCOut != "",
+				Doc:            docOut != "",
+				Docs:           docsOut != "",
+				ES:             esOut != "",
+				Go:             goOut != "",
+				GogoFast:       gogoFastOut != "",
+				GolangDeepCopy: golangDeepCopyOut != "",
+				GolangJSONShim: golangJsonShimOut != "",
+				GoGRPC:         goGrpcOut != "",
+				GRPCGateway:    grpcGatewayOut != "",
+				GRPCWeb:        grpcWebOut != "",
+				ImprobableTS:   improbableTSOut != "",
+				JavaGRPC:       javaGrpcOut != "",
+				JavascriptGRPC: jsGRPCOut != "",
+				JSONSchema:     jsonSchemaOut != "",
+				ObjectiveCGRPC: objcGRPCOut != "",
+				PHPGRPC:        phpGRPCOut != "",
+				PythonGRPC:     pythonGRPCOut != "",
+				RubyGRPC:       rubyGRPCOut != "",
+				TS:             tsOut != "",
+				Validate:       validateOut != "",
+			}
+
+			if m == nil {
+				config, err := toolsConfig(env)
+				if err != nil {
+					return err
+				}
+				config.Refresh = config.Refresh || refresh
+				config.Verbose = config.Verbose || verbose
+				m, err = tools.NewToolManager(config)
+				if err != nil {
+					return err
+				}
 			}
 
-			if err := m.RunProtoc(args, protos, env["PROTO_INCLUDES_DIR"]); err != nil {
+			result, err = m.RunProtoc(c.Context(), protocConfig, stripProtogFlags(args), protos, env["PROTO_INCLUDES_DIR"])
+			if err != nil {
 				return err
 			}
 
@@ -151,6 +167,13 @@ func Run(args []string, env map[string]string) error {
 	}
 
 	cmd.SetArgs(args)
+	cmd.CompletionOptions.DisableDefaultCmd = true
+
+	cmd.AddCommand(newExecCommand(env))
+	cmd.AddCommand(newFetchCommand(env))
+	cmd.AddCommand(newIncludesCommand(env))
+	cmd.AddCommand(newOutdatedCommand(env, pins))
+	cmd.AddCommand(newUpgradeCommand(env, pins))
 
 	cmd.Flags().StringVar(&cppOut, "cpp_out", "", "Generate C++ header and source.")
 	cmd.Flags().StringVar(&cppGRPCOut, "grpc_cpp_out", "", "Generate C++ gRPC header and source.")
@@ -203,13 +226,123 @@ func Run(args []string, env map[string]string) error {
 
 	cmd.Flags().StringVar(&gogoFastOut, "gogofast_out", "", "Generate Go source file using gogofast.")
 
-	return cmd.Execute()
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "Resolve latest versions of tools again instead of using cached ones.")
+	cmd.Flags().BoolVar(&verbose, "verbose", false, "Print the environment protoc and plugin builds are run with.")
+
+	err = cmd.ExecuteContext(ctx)
+	return result, err
+}
+
+// ToolsConfig returns the configuration of tools from env and the versions files in the current directory or its
+// parents, the same as when running protog.
+func ToolsConfig(env map[string]string) (tools.Config, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return tools.Config{}, err
+	}
+	pins, err := findPins(env, cwd)
+	if err != nil {
+		return tools.Config{}, err
+	}
+	return toolsConfig(withPins(env, pins))
+}
+
+// toolsConfig returns the configuration of tools from env.
+func toolsConfig(env map[string]string) (tools.Config, error) {
+	latestTTL, err := parseLatestTTL(env)
+	if err != nil {
+		return tools.Config{}, err
+	}
+
+	versions := tools.Versions{}
+	pluginEnv := map[string]map[string]string{}
+	for _, tool := range tools.ToolNames() {
+		if v := env[tools.VersionVar(tool)]; v != "" {
+			versions[tool] = v
+		}
+		if v := env[tools.PluginEnvVar(tool)]; v != "" {
+			vars, err := parseEnvList(v)
+			if err != nil {
+				return tools.Config{}, fmt.Errorf("invalid %s: %w", tools.PluginEnvVar(tool), err)
+			}
+			pluginEnv[tool] = vars
+		}
+	}
+
+	return tools.Config{
+		Versions: versions,
+		BSR: proto.BSRConfig{
+			URL:   env["BUF_REGISTRY_URL"],
+			Token: env["BUF_TOKEN"],
+		},
+		GoToolchain:         env["GOTOOLCHAIN"],
+		GoEnv:               tools.GoModuleEnv(env),
+		NpmRegistry:         npmRegistry(env),
+		GitHubToken:         env["GITHUB_TOKEN"],
+		LatestTTL:           latestTTL,
+		Refresh:             env["PROTOG_REFRESH"] == "true",
+		GoModVersions:       env["PROTOG_GO_MOD_VERSIONS"] == "true",
+		GoWork:              env["GOWORK"],
+		PackageJSONVersions: env["PROTOG_PACKAGE_JSON_VERSIONS"] == "true",
+		EnvPassthrough:      splitList(env["PROTOG_ENV_PASSTHROUGH"]),
+		PluginEnv:           pluginEnv,
+		Verbose:             env["PROTOG_VERBOSE"] == "true",
+	}, nil
+}
+
+// splitList splits a comma-separated list, ignoring empty elements and surrounding whitespace.
+func splitList(s string) []string {
+	var res []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// parseEnvList parses comma-separated variables such as A=1,B=2. A comma not followed by a variable name and =
+// is part of the previous value, e.g. NODE_OPTIONS=--a,--b.
+func parseEnvList(s string) (map[string]string, error) {
+	res := map[string]string{}
+	var key string
+	for _, e := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(e, "="); ok && isEnvName(strings.TrimSpace(k)) {
+			key = strings.TrimSpace(k)
+			res[key] = v
+			continue
+		}
+		if strings.TrimSpace(e) == "" {
+			continue
+		}
+		if key == "" {
+			return nil, fmt.Errorf("%q is not a variable assignment such as NAME=value", e)
+		}
+		res[key] += "," + e
+	}
+	return res, nil
+}
+
+func isEnvName(s string) bool {
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for _, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
 }
 
 func mkdir(path string) error {
 	if path == "" {
 		return nil
 	}
+	// protoc writes output to an archive instead of a directory for these extensions.
+	if ext := filepath.Ext(path); ext == ".zip" || ext == ".jar" || ext == ".srcjar" {
+		path = filepath.Dir(path)
+	}
 
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return err
@@ -217,3 +350,36 @@ func mkdir(path string) error {
 
 	return nil
 }
+
+// npmRegistry returns the npm registry configured the same way as for npm itself.
+func npmRegistry(env map[string]string) string {
+	if r := env["NPM_CONFIG_REGISTRY"]; r != "" {
+		return r
+	}
+	return env["npm_config_registry"]
+}
+
+// stripProtogFlags removes flags only understood by protog from args passed through to protoc.
+func stripProtogFlags(args []string) []string {
+	res := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "--refresh" || strings.HasPrefix(arg, "--refresh=") || arg == "--verbose" || strings.HasPrefix(arg, "--verbose=") {
+			continue
+		}
+		res = append(res, arg)
+	}
+	return res
+}
+
+// parseLatestTTL returns how long resolved latest versions are reused, set as a duration such as 1h.
+func parseLatestTTL(env map[string]string) (time.Duration, error) {
+	v := env["PROTOG_LATEST_TTL"]
+	if v == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid PROTOG_LATEST_TTL: %w", err)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripProtogFlags(t *testing.T) {
+	require.Equal(t,
+		[]string{"--go_out=out", "-I.", "foo.proto"},
+		stripProtogFlags([]string{"--refresh", "--go_out=out", "-I.", "--refresh=true", "--verbose", "foo.proto"}))
+}
+
+func TestParseEnvList(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected map[string]string
+		err      string
+	}{
+		{
+			name:     "single",
+			value:    "NODE_OPTIONS=--max-old-space-size=4096",
+			expected: map[string]string{"NODE_OPTIONS": "--max-old-space-size=4096"},
+		},
+		{
+			name:     "multiple",
+			value:    "A=1, B=2,",
+			expected: map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:     "comma in value",
+			value:    "NODE_OPTIONS=--a,--b,C=3",
+			expected: map[string]string{"NODE_OPTIONS": "--a,--b", "C": "3"},
+		},
+		{
+			name:  "not an assignment",
+			value: "--a",
+			err:   `"--a" is not a variable assignment such as NAME=value`,
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			vars, err := parseEnvList(tt.value)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, vars)
+		})
+	}
+}
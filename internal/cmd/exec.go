@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/curioswitch/protog/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+func newExecCommand(env map[string]string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec TOOL[@VERSION] [-- ARGS]",
+		Short: "Run a tool such as buf, grpcurl, protolint, clang-format, go or node, installing it if needed.",
+		Long: "Run a tool such as buf, grpcurl, protolint, clang-format, go or node, installing it if needed. The version " +
+			"is the one configured for the tool, or the latest, unless set after @.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			tool, version, args, err := parseExecArgs(args)
+			if err != nil {
+				return err
+			}
+
+			config, err := toolsConfig(env)
+			if err != nil {
+				return err
+			}
+			m, err := tools.NewToolManager(config)
+			if err != nil {
+				return err
+			}
+
+			cmd, err := m.Command(c.Context(), tool, version, args...)
+			if err != nil {
+				return err
+			}
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = c.OutOrStdout()
+			cmd.Stderr = c.ErrOrStderr()
+
+			// The tool reports its own errors, only its exit status is returned.
+			c.SilenceErrors = true
+			c.SilenceUsage = true
+			return cmd.Run()
+		},
+	}
+
+	// Flags after the tool are its own.
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
+// parseExecArgs splits the arguments of exec into the tool, its version if set and the arguments to run it with.
+func parseExecArgs(args []string) (string, string, []string, error) {
+	tool, version, _ := strings.Cut(args[0], "@")
+	name, ok := tools.CommandTool(tool)
+	if !ok {
+		return "", "", nil, fmt.Errorf("unknown tool %s", tool)
+	}
+	args = args[1:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	return name, version, args, nil
+}
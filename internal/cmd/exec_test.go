@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExecArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		tool    string
+		version string
+		rest    []string
+		err     string
+	}{
+		{
+			name: "tool",
+			args: []string{"buf", "lint"},
+			tool: "buf",
+			rest: []string{"lint"},
+		},
+		{
+			name:    "version",
+			args:    []string{"buf@1.28.1", "--", "lint", "--error-format=json"},
+			tool:    "buf",
+			version: "1.28.1",
+			rest:    []string{"lint", "--error-format=json"},
+		},
+		{
+			name: "command",
+			args: []string{"go", "version"},
+			tool: "golang",
+			rest: []string{"version"},
+		},
+		{
+			name: "no args",
+			args: []string{"node"},
+			tool: "nodejs",
+			rest: []string{},
+		},
+		{
+			name: "unknown",
+			args: []string{"make"},
+			err:  "unknown tool make",
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			tool, version, rest, err := parseExecArgs(tt.args)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.tool, tool)
+			require.Equal(t, tt.version, version)
+			require.Equal(t, tt.rest, rest)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/curioswitch/protog/internal/proto"
+	"github.com/spf13/cobra"
+)
+
+func newFetchCommand(env map[string]string) *cobra.Command {
+	var reflectTarget string
+	var plaintext bool
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch proto definitions into the includes directory.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			ctx, cancel := context.WithTimeout(c.Context(), time.Minute)
+			defer cancel()
+
+			written, err := proto.FetchReflection(ctx, proto.ReflectConfig{
+				Target:    reflectTarget,
+				Plaintext: plaintext,
+				Format:    format,
+				Dir:       includesDir(env),
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, path := range written {
+				fmt.Fprintln(c.OutOrStdout(), path)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&reflectTarget, "reflect", "", "Address of a server, e.g. localhost:8080, to fetch files from using gRPC server reflection.")
+	cmd.Flags().BoolVar(&plaintext, "plaintext", false, "Connect to the server without TLS.")
+	_ = cmd.MarkFlagRequired("reflect")
+	cmd.Flags().StringVar(&format, "format", proto.ReflectFormatProto, fmt.Sprintf("Output format, either %s or %s.", proto.ReflectFormatProto, proto.ReflectFormatDescriptorSet))
+
+	return cmd
+}
+
+func includesDir(env map[string]string) string {
+	if dir := env["PROTO_INCLUDES_DIR"]; dir != "" {
+		return dir
+	}
+	return proto.DefaultIncludesDir
+}
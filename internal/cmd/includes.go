@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/curioswitch/protog/internal/proto"
+	"github.com/curioswitch/protog/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+func newIncludesCommand(env map[string]string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "includes",
+		Short: "Manage imported protos downloaded by protog.",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List downloaded includes.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			cacheDir, err := tools.IncludesCacheDir()
+			if err != nil {
+				return err
+			}
+			return printIncludes(c.OutOrStdout(), proto.ListIncludes(env["PROTO_INCLUDES_DIR"], cacheDir))
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "update [prefix]",
+		Short: "Download includes again, all of them or those matching an import prefix.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			cacheDir, err := tools.IncludesCacheDir()
+			if err != nil {
+				return err
+			}
+			var prefix string
+			if len(args) > 0 {
+				prefix = args[0]
+			}
+			statuses, err := proto.UpdateIncludes(c.Context(), env["PROTO_INCLUDES_DIR"], cacheDir, prefix)
+			if err != nil {
+				return err
+			}
+			return printIncludes(c.OutOrStdout(), statuses)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "verify",
+		Short: "Verify downloaded includes against recorded hashes, downloading again any that are corrupted or stale.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			cacheDir, err := tools.IncludesCacheDir()
+			if err != nil {
+				return err
+			}
+			statuses, err := proto.VerifyIncludes(c.Context(), env["PROTO_INCLUDES_DIR"], cacheDir)
+			if err != nil {
+				return err
+			}
+			for _, s := range statuses {
+				status := "ok"
+				if s.Problem != "" {
+					status = "refetched: " + s.Problem
+				}
+				fmt.Fprintf(c.OutOrStdout(), "%s: %s\n", s.Path, status)
+			}
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+func printIncludes(out io.Writer, statuses []proto.IncludeStatus) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIXES\tREPOSITORY\tREF\tPATH\tHASH")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", strings.Join(s.Prefixes, ","), s.Repo, s.Ref, s.Path, s.Hash)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/curioswitch/protog/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+func newOutdatedCommand(env map[string]string, pins []pin) *cobra.Command {
+	return &cobra.Command{
+		Use:   "outdated",
+		Short: "List tools with pinned versions and the latest versions available.",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			m, err := newVersionsToolManager(env)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(c.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "TOOL\tCURRENT\tWANTED\tLATEST\tMAJOR\tSOURCE")
+			for _, p := range pins {
+				s, err := m.CheckVersion(p.tool, p.version)
+				if err != nil {
+					return fmt.Errorf("checking %s: %w", p.tool, err)
+				}
+				major := ""
+				if s.MajorBump {
+					major = "yes"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.tool, p.version, orDash(s.Wanted), s.Latest, major, pinSource(p))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newUpgradeCommand(env map[string]string, pins []pin) *cobra.Command {
+	var within string
+
+	cmd := &cobra.Command{
+		Use:   "upgrade [tool]",
+		Short: "Upgrade pinned versions of tools, all of them or one, in the versions files they are pinned in.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			var tool string
+			if len(args) > 0 {
+				tool = args[0]
+				if !tools.IsTool(tool) {
+					return fmt.Errorf("unknown tool %s", tool)
+				}
+			}
+
+			m, err := newVersionsToolManager(env)
+			if err != nil {
+				return err
+			}
+
+			found := false
+			for _, p := range pins {
+				if tool != "" && p.tool != tool {
+					continue
+				}
+				found = true
+				if p.path == "" {
+					fmt.Fprintf(c.OutOrStdout(), "%s: skipped, pinned by %s in the environment\n", p.tool, p.envVar)
+					continue
+				}
+				upgraded, err := m.UpgradeVersion(p.tool, p.version, within)
+				if err != nil {
+					fmt.Fprintf(c.OutOrStdout(), "%s: skipped, %v\n", p.tool, err)
+					continue
+				}
+				if upgraded == p.version {
+					fmt.Fprintf(c.OutOrStdout(), "%s: %s is up to date\n", p.tool, p.version)
+					continue
+				}
+				if err := writePin(p, upgraded); err != nil {
+					return err
+				}
+				fmt.Fprintf(c.OutOrStdout(), "%s: %s -> %s in %s\n", p.tool, p.version, upgraded, p.path)
+			}
+			if tool != "" && !found {
+				return fmt.Errorf("%s is not pinned in %s or %s", tool, protogVersionsFile, toolVersionsFile)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&within, "within", "", "Limit upgrades to the current major version with major, the current minor version with minor, or to a constraint such as <2.")
+
+	return cmd
+}
+
+// newVersionsToolManager returns a ToolManager for checking versions, always resolving latest versions again.
+func newVersionsToolManager(env map[string]string) (*tools.ToolManager, error) {
+	config, err := toolsConfig(env)
+	if err != nil {
+		return nil, err
+	}
+	config.Refresh = true
+	return tools.NewToolManager(config)
+}
+
+func pinSource(p pin) string {
+	if p.path == "" {
+		return p.envVar
+	}
+	return p.path
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/curioswitch/protog/internal/proto"
+	"github.com/curioswitch/protog/internal/tools"
+)
+
+const (
+	// protogVersionsFile sets versions with the same variables as the environment, e.g. PROTOC_VERSION=24.4.
+	protogVersionsFile = ".protog-versions"
+	// toolVersionsFile is the file used by asdf, with lines of a tool name and version, e.g. protoc 24.4.
+	toolVersionsFile = ".tool-versions"
+)
+
+// pin is a version set for a tool and where it was set.
+type pin struct {
+	tool    string
+	envVar  string
+	version string
+	// path is the versions file the version was read from, or empty if it was set in the environment.
+	path string
+	line int
+}
+
+// findPins returns the versions set for tools in env or the nearest .protog-versions and .tool-versions in dir or
+// its parents, sorted by tool. Versions set in env take precedence over .protog-versions, which takes precedence
+// over .tool-versions.
+func findPins(env map[string]string, dir string) ([]pin, error) {
+	pins := map[string]pin{}
+	for _, tool := range tools.ToolNames() {
+		envVar := tools.VersionVar(tool)
+		if v := env[envVar]; v != "" {
+			pins[envVar] = pin{tool: tool, envVar: envVar, version: v}
+		}
+	}
+
+	for _, file := range []struct {
+		name  string
+		parse func(path string) ([]pin, error)
+	}{
+		{name: protogVersionsFile, parse: parseProtogVersions},
+		{name: toolVersionsFile, parse: parseToolVersions},
+	} {
+		path, err := proto.FindUp(dir, file.name)
+		if err != nil {
+			return nil, err
+		}
+		if path == "" {
+			continue
+		}
+		filePins, err := file.parse(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range filePins {
+			if _, ok := pins[p.envVar]; !ok {
+				pins[p.envVar] = p
+			}
+		}
+	}
+
+	res := make([]pin, 0, len(pins))
+	for _, p := range pins {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].tool < res[j].tool
+	})
+	return res, nil
+}
+
+// withPins returns env with the versions of pins added.
+func withPins(env map[string]string, pins []pin) map[string]string {
+	res := make(map[string]string, len(env))
+	for k, v := range env {
+		res[k] = v
+	}
+	for _, p := range pins {
+		res[p.envVar] = p.version
+	}
+	return res
+}
+
+// parseProtogVersions parses lines of VARIABLE=version, where the variable may also be the name of the tool.
+func parseProtogVersions(path string) ([]pin, error) {
+	var pins []pin
+	err := readVersionLines(path, func(lineNum int, line string) error {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected VARIABLE=version", path, lineNum)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		tool, ok := toolForVar(key)
+		if !ok {
+			if !tools.IsTool(key) {
+				return fmt.Errorf("%s:%d: unknown tool %s", path, lineNum, key)
+			}
+			tool = key
+		}
+		key = tools.VersionVar(tool)
+		pins = append(pins, pin{tool: tool, envVar: key, version: value, path: path, line: lineNum})
+		return nil
+	})
+	return pins, err
+}
+
+// parseToolVersions parses an asdf .tool-versions, ignoring tools protog does not manage and versions that are
+// not released versions such as system or ref:main.
+func parseToolVersions(path string) ([]pin, error) {
+	var pins []pin
+	err := readVersionLines(path, func(lineNum int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil
+		}
+		if !tools.IsTool(fields[0]) {
+			return nil
+		}
+		key := tools.VersionVar(fields[0])
+		// Later versions are fallbacks which protog does not support.
+		v := fields[1]
+		if v == "system" || strings.Contains(v, ":") {
+			return nil
+		}
+		pins = append(pins, pin{tool: fields[0], envVar: key, version: v, path: path, line: lineNum})
+		return nil
+	})
+	return pins, err
+}
+
+func readVersionLines(path string, fn func(lineNum int, line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if err := fn(lineNum, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func toolForVar(key string) (string, bool) {
+	for _, tool := range tools.ToolNames() {
+		if tools.VersionVar(tool) == key {
+			return tool, true
+		}
+	}
+	return "", false
+}
+
+// writePin replaces the version of p in the versions file it was read from, keeping the rest of the file, including
+// comments, as is.
+func writePin(p pin, version string) error {
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(b), "\n")
+	if p.line > len(lines) {
+		return fmt.Errorf("%s:%d: line not found", p.path, p.line)
+	}
+	line := lines[p.line-1]
+	// The version follows the variable and = in .protog-versions, and the tool name in .tool-versions.
+	start := strings.IndexByte(line, '=') + 1
+	if start == 0 {
+		start = strings.Index(line, p.tool) + len(p.tool)
+	}
+	i := strings.Index(line[start:], p.version)
+	if i < 0 {
+		return fmt.Errorf("%s:%d: version %s not found", p.path, p.line, p.version)
+	}
+	i += start
+	lines[p.line-1] = line[:i] + version + line[i+len(p.version):]
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.path, []byte(strings.Join(lines, "\n")), info.Mode())
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithVersionFiles(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	sub := filepath.Join(project, "api")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".tool-versions"), []byte(`golang 1.21.3
+nodejs 20.8.0
+python 3.11.0
+protoc 24.3
+protoc-gen-go system
+protoc-gen-go-grpc ref:main 1.3.0
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, ".protog-versions"), []byte(`# Pinned for the generated code in api.
+PROTOC_VERSION=24.4
+protoc-gen-go = 1.31.0 # same as google.golang.org/protobuf
+PROTOC_GEN_ES_VERSION=^1.3
+`), 0644))
+
+	env := map[string]string{
+		"PROTOC_GEN_ES_VERSION": "1.3.1",
+		"NODEJS_VERSION":        "",
+		"PATH":                  "/usr/bin",
+	}
+	pins, err := findPins(env, sub)
+	require.NoError(t, err)
+	require.Equal(t, []pin{
+		{tool: "golang", envVar: "GO_VERSION", version: "1.21.3", path: filepath.Join(root, ".tool-versions"), line: 1},
+		{tool: "nodejs", envVar: "NODEJS_VERSION", version: "20.8.0", path: filepath.Join(root, ".tool-versions"), line: 2},
+		{tool: "protoc", envVar: "PROTOC_VERSION", version: "24.4", path: filepath.Join(project, ".protog-versions"), line: 2},
+		{tool: "protoc-gen-es", envVar: "PROTOC_GEN_ES_VERSION", version: "1.3.1"},
+		{tool: "protoc-gen-go", envVar: "PROTOC_GEN_GO_VERSION", version: "1.31.0", path: filepath.Join(project, ".protog-versions"), line: 3},
+	}, pins)
+	require.Equal(t, map[string]string{
+		"GO_VERSION":            "1.21.3",
+		"NODEJS_VERSION":        "20.8.0",
+		"PATH":                  "/usr/bin",
+		"PROTOC_VERSION":        "24.4",
+		"PROTOC_GEN_ES_VERSION": "1.3.1",
+		"PROTOC_GEN_GO_VERSION": "1.31.0",
+	}, withPins(env, pins))
+}
+
+func TestParseProtogVersionsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".protog-versions")
+
+	require.NoError(t, os.WriteFile(path, []byte("PROTOC_VERSION 24.4\n"), 0644))
+	_, err := parseProtogVersions(path)
+	require.ErrorContains(t, err, ".protog-versions:1: expected VARIABLE=version")
+
+	require.NoError(t, os.WriteFile(path, []byte("\nPROTOC_GEN_FOO_VERSION=1.0.0\n"), 0644))
+	_, err = parseProtogVersions(path)
+	require.ErrorContains(t, err, ".protog-versions:2: unknown tool PROTOC_GEN_FOO_VERSION")
+}
+
+func TestWritePin(t *testing.T) {
+	dir := t.TempDir()
+
+	protogVersions := filepath.Join(dir, ".protog-versions")
+	require.NoError(t, os.WriteFile(protogVersions, []byte(`# Pinned for the generated code in api.
+PROTOC_VERSION=24.4
+protoc-gen-go = v1.31.0 # same as google.golang.org/protobuf
+`), 0644))
+	pins, err := parseProtogVersions(protogVersions)
+	require.NoError(t, err)
+	require.NoError(t, writePin(pins[1], "v1.32.0"))
+	b, err := os.ReadFile(protogVersions)
+	require.NoError(t, err)
+	require.Equal(t, `# Pinned for the generated code in api.
+PROTOC_VERSION=24.4
+protoc-gen-go = v1.32.0 # same as google.golang.org/protobuf
+`, string(b))
+
+	toolVersions := filepath.Join(dir, ".tool-versions")
+	require.NoError(t, os.WriteFile(toolVersions, []byte("golang 1.21.3\nprotoc 24.4 24.3\n"), 0644))
+	pins, err = parseToolVersions(toolVersions)
+	require.NoError(t, err)
+	require.NoError(t, writePin(pins[1], "25.1"))
+	b, err = os.ReadFile(toolVersions)
+	require.NoError(t, err)
+	require.Equal(t, "golang 1.21.3\nprotoc 25.1 24.3\n", string(b))
+}
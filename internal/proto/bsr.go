@@ -0,0 +1,264 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BSRConfig configures downloading modules from the Buf Schema Registry.
+type BSRConfig struct {
+	// URL is the base URL of the registry API. If empty, it is derived from the remote of each module, e.g.
+	// https://buf.build.
+	URL string
+	// Token authenticates requests to the registry, as with BUF_TOKEN for buf.
+	Token string
+}
+
+// bsrCommitRe matches a commit ID as pinned by buf.lock, which is a dashless UUID.
+var bsrCommitRe = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// bsrModule is a reference to a module in the registry, such as buf.build/googleapis/googleapis.
+type bsrModule struct {
+	remote string
+	owner  string
+	name   string
+	// ref is the commit pinned by buf.lock, a label specified in buf.yaml, or empty for the default label.
+	ref string
+}
+
+func (m bsrModule) String() string {
+	return path.Join(m.remote, m.owner, m.name)
+}
+
+// bsrModules are the deps declared by a buf.yaml, downloaded into dir the first time an import needs to be
+// resolved. Each commit is downloaded to its own directory so changing the pin in buf.lock is never served a
+// stale tree.
+type bsrModules struct {
+	config BSRConfig
+	dir    string
+	client *http.Client
+
+	modules []bsrModule
+
+	// roots are set after modules have been downloaded.
+	roots []string
+}
+
+func readBSRModules(bufYAML string, dir string, config BSRConfig) (*bsrModules, error) {
+	b, err := os.ReadFile(bufYAML)
+	if err != nil {
+		return nil, err
+	}
+	var cfg struct {
+		Deps []string `yaml:"deps"`
+	}
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+
+	pins, err := readBufLock(filepath.Join(filepath.Dir(bufYAML), "buf.lock"))
+	if err != nil {
+		return nil, err
+	}
+
+	mods := &bsrModules{
+		config: config,
+		dir:    dir,
+		client: http.DefaultClient,
+	}
+	for _, dep := range cfg.Deps {
+		name, ref, _ := strings.Cut(dep, ":")
+		parts := strings.Split(name, "/")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid dep %q, expected remote/owner/module", dep)
+		}
+		mod := bsrModule{remote: parts[0], owner: parts[1], name: parts[2], ref: ref}
+		if commit, ok := pins[mod.String()]; ok {
+			mod.ref = commit
+		}
+		mods.modules = append(mods.modules, mod)
+	}
+
+	return mods, nil
+}
+
+// readBufLock returns the commits pinned by a buf.lock, keyed by module name. Both v1 and v2 formats are
+// supported.
+func readBufLock(lockPath string) (map[string]string, error) {
+	b, err := os.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lock struct {
+		Deps []struct {
+			// v2
+			Name string `yaml:"name"`
+			// v1
+			Remote     string `yaml:"remote"`
+			Owner      string `yaml:"owner"`
+			Repository string `yaml:"repository"`
+
+			Commit string `yaml:"commit"`
+		} `yaml:"deps"`
+	}
+	if err := yaml.Unmarshal(b, &lock); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", lockPath, err)
+	}
+
+	pins := map[string]string{}
+	for _, dep := range lock.Deps {
+		name := dep.Name
+		if name == "" {
+			name = path.Join(dep.Remote, dep.Owner, dep.Repository)
+		}
+		pins[name] = dep.Commit
+	}
+
+	return pins, nil
+}
+
+func (m *bsrModules) root(imp string) (string, error) {
+	if m.roots == nil {
+		m.roots = []string{}
+		for _, mod := range m.modules {
+			root, err := m.download(mod)
+			if err != nil {
+				return "", fmt.Errorf("downloading %s: %w", mod, err)
+			}
+			m.roots = append(m.roots, root)
+		}
+	}
+
+	for _, root := range m.roots {
+		if fileExists(filepath.Join(root, filepath.FromSlash(imp))) {
+			return root, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (m *bsrModules) download(mod bsrModule) (string, error) {
+	modDir := filepath.Join(m.dir, mod.remote, mod.owner, mod.name)
+	if bsrCommitRe.MatchString(mod.ref) {
+		dir := filepath.Join(modDir, mod.ref)
+		if _, err := os.Stat(dir); err == nil {
+			return dir, nil
+		}
+	}
+
+	baseURL := m.config.URL
+	if baseURL == "" {
+		baseURL = "https://" + mod.remote
+	}
+
+	// buf.registry.module.v1.DownloadService/Download using the Connect protocol with JSON.
+	type name struct {
+		Owner  string `json:"owner"`
+		Module string `json:"module"`
+		Ref    string `json:"ref,omitempty"`
+	}
+	type resourceRef struct {
+		Name name `json:"name"`
+	}
+	type value struct {
+		ResourceRef resourceRef `json:"resourceRef"`
+	}
+	reqBody, err := json.Marshal(struct {
+		Values []value `json:"values"`
+	}{
+		Values: []value{{ResourceRef: resourceRef{Name: name{Owner: mod.owner, Module: mod.name, Ref: mod.ref}}}},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/buf.registry.module.v1.DownloadService/Download", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	if m.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.config.Token)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("invalid status code: %v: %s", resp.StatusCode, msg)
+	}
+
+	var res struct {
+		Contents []struct {
+			Commit struct {
+				ID string `json:"id"`
+			} `json:"commit"`
+			Files []struct {
+				Path    string `json:"path"`
+				Content []byte `json:"content"`
+			} `json:"files"`
+		} `json:"contents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", fmt.Errorf("parsing download response: %w", err)
+	}
+	if len(res.Contents) != 1 || res.Contents[0].Commit.ID == "" {
+		return "", fmt.Errorf("unexpected download response with %d contents", len(res.Contents))
+	}
+	content := res.Contents[0]
+
+	dir := filepath.Join(modDir, content.Commit.ID)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	// Write into a temporary directory that is moved into place once complete so an interrupted download is not
+	// mistaken for a complete one.
+	if err := os.MkdirAll(modDir, 0755); err != nil {
+		return "", err
+	}
+	tmpDir, err := os.MkdirTemp(modDir, "download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, f := range content.Files {
+		p := path.Clean(f.Path)
+		if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+			return "", fmt.Errorf("invalid file path in module: %s", f.Path)
+		}
+		dst := filepath.Join(tmpDir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(dst, f.Content, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
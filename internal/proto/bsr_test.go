@@ -0,0 +1,105 @@
+package proto
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBSRModulesRoot(t *testing.T) {
+	const commit = "28151c0d0a1641bf938a7672c500e01d"
+
+	var requests []string
+	// The handler runs on another goroutine, where require cannot stop the test.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/buf.registry.module.v1.DownloadService/Download", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var req struct {
+			Values []struct {
+				ResourceRef struct {
+					Name struct {
+						Owner  string `json:"owner"`
+						Module string `json:"module"`
+						Ref    string `json:"ref"`
+					} `json:"name"`
+				} `json:"resourceRef"`
+			} `json:"values"`
+		}
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) || !assert.NotEmpty(t, req.Values) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		name := req.Values[0].ResourceRef.Name
+		requests = append(requests, name.Owner+"/"+name.Module+":"+name.Ref)
+
+		var path string
+		id := commit
+		switch name.Module {
+		case "googleapis":
+			path = "google/api/annotations.proto"
+		case "protovalidate":
+			path = "buf/validate/validate.proto"
+			id = "0123456789abcdef0123456789abcdef"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"contents": []interface{}{
+				map[string]interface{}{
+					"commit": map[string]string{"id": id},
+					"files": []interface{}{
+						map[string]interface{}{"path": path, "content": []byte("syntax = \"proto3\";")},
+					},
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	bufYAML := filepath.Join(dir, "buf.yaml")
+	require.NoError(t, os.WriteFile(bufYAML, []byte(`version: v1
+deps:
+  - buf.build/googleapis/googleapis
+  - buf.build/bufbuild/protovalidate:v0.1.0
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "buf.lock"), []byte(`version: v1
+deps:
+  - remote: buf.build
+    owner: googleapis
+    repository: googleapis
+    commit: `+commit+`
+`), 0644))
+
+	includesDir := filepath.Join(dir, "includes")
+	config := BSRConfig{URL: srv.URL, Token: "secret"}
+	mods, err := readBSRModules(bufYAML, includesDir, config)
+	require.NoError(t, err)
+
+	root, err := mods.root("google/api/annotations.proto")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(includesDir, "buf.build", "googleapis", "googleapis", commit), root)
+
+	root, err = mods.root("buf/validate/validate.proto")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(includesDir, "buf.build", "bufbuild", "protovalidate", "0123456789abcdef0123456789abcdef"), root)
+
+	root, err = mods.root("acme/v1/acme.proto")
+	require.NoError(t, err)
+	require.Empty(t, root)
+
+	require.Equal(t, []string{"googleapis/googleapis:" + commit, "bufbuild/protovalidate:v0.1.0"}, requests)
+
+	// Pinned commits that are already downloaded are not requested again.
+	requests = nil
+	mods, err = readBSRModules(bufYAML, includesDir, config)
+	require.NoError(t, err)
+	_, err = mods.root("google/api/annotations.proto")
+	require.NoError(t, err)
+	require.Equal(t, []string{"bufbuild/protovalidate:v0.1.0"}, requests)
+}
@@ -0,0 +1,88 @@
+package proto
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// UnresolvedImport is an import that no proto_path, include spec or dependency provides.
+type UnresolvedImport struct {
+	// File is the path of the importing file.
+	File string
+	// Line is the line number of the import statement.
+	Line int
+	// Import is the imported path.
+	Import string
+	// Hint suggests how to provide the import.
+	Hint string
+}
+
+func (u UnresolvedImport) String() string {
+	return fmt.Sprintf("%s:%d: import %q not found: %s", u.File, u.Line, u.Import, u.Hint)
+}
+
+// UnresolvedImportsError is returned by FetchIncludes when any import cannot be resolved, which would likely cause
+// protoc to fail with a less actionable message.
+type UnresolvedImportsError struct {
+	Imports []UnresolvedImport
+}
+
+func (e *UnresolvedImportsError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d unresolved import(s):", len(e.Imports))
+	for _, u := range e.Imports {
+		sb.WriteString("\n  ")
+		sb.WriteString(u.String())
+	}
+	return sb.String()
+}
+
+// bsrHints are modules on the Buf Schema Registry known to provide an import prefix, suggested for imports that
+// are beyond what the corresponding include spec fetches.
+var bsrHints = []struct {
+	prefix string
+	module string
+}{
+	{prefix: "buf/validate/", module: "buf.build/bufbuild/protovalidate"},
+	{prefix: "cosmos_proto/", module: "buf.build/cosmos/cosmos-proto"},
+	{prefix: "envoy/", module: "buf.build/envoyproxy/envoy"},
+	{prefix: "google/", module: "buf.build/googleapis/googleapis"},
+	{prefix: "grpc/", module: "buf.build/grpc/grpc"},
+	{prefix: "opentelemetry/", module: "buf.build/opentelemetry/opentelemetry"},
+	{prefix: "protoc-gen-openapiv2/", module: "buf.build/grpc-ecosystem/grpc-gateway"},
+	{prefix: "udpa/", module: "buf.build/cncf/xds"},
+	{prefix: "validate/", module: "buf.build/envoyproxy/protoc-gen-validate"},
+	{prefix: "xds/", module: "buf.build/cncf/xds"},
+}
+
+// suggest returns a hint on how to provide an unresolved import, preferring the most specific known source.
+func suggest(imp string, store includeStore) string {
+	for _, s := range includeSpecs {
+		if strings.HasPrefix(imp, s.prefix) {
+			return fmt.Sprintf("%s is fetched from %s at %s into %s but does not contain this file, it may have moved upstream",
+				strings.TrimSuffix(s.prefix, "/"), s.repo, s.ref, filepath.Join(store.root(s), s.dir))
+		}
+	}
+
+	for _, h := range bsrHints {
+		if strings.HasPrefix(imp, h.prefix) {
+			return fmt.Sprintf("it may be provided by %s, add it to the deps of buf.yaml", h.module)
+		}
+	}
+
+	// Imports starting with a domain are likely from a Go module.
+	if first, _, ok := strings.Cut(imp, "/"); ok && strings.Contains(first, ".") {
+		mod := path.Dir(imp)
+		switch first {
+		case "github.com", "gitlab.com", "bitbucket.org":
+			if parts := strings.SplitN(imp, "/", 4); len(parts) == 4 {
+				mod = strings.Join(parts[:3], "/")
+			}
+		}
+		return fmt.Sprintf("if it is provided by a Go module, add it to go.mod, e.g. go get %s", mod)
+	}
+
+	return "add the directory containing it with --proto_path"
+}
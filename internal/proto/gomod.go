@@ -0,0 +1,107 @@
+package proto
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// GoModDownloader downloads a Go module, returning the directory containing its extracted source.
+type GoModDownloader func(mod module.Version) (string, error)
+
+// goModules are the modules required by a go.mod file, used to resolve imports such as
+// github.com/acme/shared/proto/money.proto to the version of the module the project depends on.
+type goModules struct {
+	download GoModDownloader
+
+	requires []goRequire
+	// dirs caches directories of modules that have been resolved already.
+	dirs map[string]string
+}
+
+type goRequire struct {
+	path string
+	// mod is the module to download, which differs from path when a replace directive is present.
+	mod module.Version
+	// dir is set instead of mod when replaced by a local directory.
+	dir string
+}
+
+func readGoModules(path string, download GoModDownloader) (*goModules, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.Parse(path, b, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	replaces := map[module.Version]module.Version{}
+	for _, r := range f.Replace {
+		replaces[r.Old] = r.New
+	}
+
+	mods := &goModules{
+		download: download,
+		dirs:     map[string]string{},
+	}
+	for _, r := range f.Require {
+		req := goRequire{path: r.Mod.Path, mod: r.Mod}
+		// A replacement of a specific version takes precedence over one of all versions.
+		rep, ok := replaces[r.Mod]
+		if !ok {
+			rep, ok = replaces[module.Version{Path: r.Mod.Path}]
+		}
+		if ok {
+			if rep.Version == "" {
+				req.dir = rep.Path
+				if !filepath.IsAbs(req.dir) {
+					req.dir = filepath.Join(filepath.Dir(path), req.dir)
+				}
+			} else {
+				req.mod = rep
+			}
+		}
+		mods.requires = append(mods.requires, req)
+	}
+
+	return mods, nil
+}
+
+// root returns a proto_path mapping the module providing imp, using the virtual=disk form so the import path
+// resolves within the module directory. An empty string is returned if no required module provides imp.
+func (m *goModules) root(imp string) (string, error) {
+	var match *goRequire
+	for i, r := range m.requires {
+		if !strings.HasPrefix(imp, r.path+"/") {
+			continue
+		}
+		// Nested modules are more specific than their parents.
+		if match == nil || len(r.path) > len(match.path) {
+			match = &m.requires[i]
+		}
+	}
+	if match == nil {
+		return "", nil
+	}
+
+	dir, ok := m.dirs[match.path]
+	if !ok {
+		dir = match.dir
+		if dir == "" {
+			d, err := m.download(match.mod)
+			if err != nil {
+				return "", fmt.Errorf("downloading Go module %s: %w", match.mod, err)
+			}
+			dir = d
+		}
+		m.dirs[match.path] = dir
+	}
+
+	return fmt.Sprintf("%s=%s", match.path, dir), nil
+}
@@ -0,0 +1,66 @@
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/module"
+)
+
+func TestGoModulesRoot(t *testing.T) {
+	dir := t.TempDir()
+	goMod := filepath.Join(dir, "go.mod")
+	require.NoError(t, os.WriteFile(goMod, []byte(`module github.com/acme/app
+
+go 1.20
+
+require (
+	github.com/acme/shared v1.2.3
+	github.com/acme/shared/money v0.1.0
+	github.com/acme/local v1.0.0
+	github.com/acme/forked v1.0.0
+)
+
+replace github.com/acme/local => ../local
+
+replace github.com/acme/forked v1.0.0 => github.com/someone/forked v1.0.1
+`), 0644))
+
+	var downloaded []module.Version
+	mods, err := readGoModules(goMod, func(mod module.Version) (string, error) {
+		downloaded = append(downloaded, mod)
+		return filepath.Join("modcache", mod.String()), nil
+	})
+	require.NoError(t, err)
+
+	root, err := mods.root("github.com/acme/shared/proto/money.proto")
+	require.NoError(t, err)
+	require.Equal(t, "github.com/acme/shared="+filepath.Join("modcache", "github.com/acme/shared@v1.2.3"), root)
+
+	root, err = mods.root("github.com/acme/shared/money/money.proto")
+	require.NoError(t, err)
+	require.Equal(t, "github.com/acme/shared/money="+filepath.Join("modcache", "github.com/acme/shared/money@v0.1.0"), root)
+
+	root, err = mods.root("github.com/acme/local/local.proto")
+	require.NoError(t, err)
+	require.Equal(t, "github.com/acme/local="+filepath.Join(filepath.Dir(dir), "local"), root)
+
+	root, err = mods.root("github.com/acme/forked/forked.proto")
+	require.NoError(t, err)
+	require.Equal(t, "github.com/acme/forked="+filepath.Join("modcache", "github.com/someone/forked@v1.0.1"), root)
+
+	root, err = mods.root("github.com/other/other.proto")
+	require.NoError(t, err)
+	require.Empty(t, root)
+
+	// Resolved modules are only downloaded once.
+	_, err = mods.root("github.com/acme/shared/proto/other.proto")
+	require.NoError(t, err)
+	require.Equal(t, []module.Version{
+		{Path: "github.com/acme/shared", Version: "v1.2.3"},
+		{Path: "github.com/acme/shared/money", Version: "v0.1.0"},
+		{Path: "github.com/someone/forked", Version: "v1.0.1"},
+	}, downloaded)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -20,56 +21,241 @@ type includeSpec struct {
 	dir     string
 }
 
-var importRe = regexp.MustCompile(`.*import "([^"]+)";.*`)
+// archiveScheme prefixes repositories to form archive URLs, replaced in tests to serve archives locally.
+var archiveScheme = "https://"
 
-func FetchIncludes(protos []string, dir string) error {
+// url returns the go-getter source for the spec's archive. GitHub archives contain a single top-level directory
+// whose name depends on the type of ref, so it is matched with a glob.
+func (s includeSpec) url() string {
+	return fmt.Sprintf("%s%s/archive/%s.zip//%s", archiveScheme, s.repo, s.ref, path.Join("*", s.repoDir))
+}
+
+var importRe = regexp.MustCompile(`^\s*import\s+(?:public\s+|weak\s+)?"([^"]+)"\s*;`)
+
+// DefaultIncludesDir is the per-project directory for includes, relative to the current directory so IDEs can
+// find them. It is used for files written by protog fetch, and is added to the proto_path when it exists.
+var DefaultIncludesDir = filepath.Join("build", "proto-includes")
+
+// Config configures how imports are resolved by FetchIncludes.
+type Config struct {
+	// Paths are the locations parsed from the protoc command line.
+	Paths Paths
+	// Dir is a per-project directory that includes matching includeSpecs are downloaded into. If empty, they are
+	// downloaded into CacheDir instead.
+	Dir string
+	// CacheDir is the directory shared by all projects that includes are downloaded into when Dir is empty.
+	CacheDir string
+	// GoModDownload downloads modules required by the project's go.mod that provide imports. If nil, imports are
+	// not resolved from Go modules.
+	GoModDownload GoModDownloader
+	// NpmInstall installs the dependencies of the project's package.json to find packages that provide imports. If
+	// nil, imports are not resolved from npm packages.
+	NpmInstall NpmInstaller
+	// BSR configures downloading the deps of the project's buf.yaml from the Buf Schema Registry.
+	BSR BSRConfig
+}
+
+// rootResolver provides proto_path roots for imports that are not found in any existing root, for example by
+// downloading a dependency of the project.
+type rootResolver interface {
+	// root returns the proto_path that provides imp, or an empty string if it is not provided.
+	root(imp string) (string, error)
+}
+
+// FetchIncludes downloads any includes matching includeSpecs that are imported by protos, either directly or
+// transitively through other imported files. Includes downloaded to the shared cache directory, as well as imports
+// provided by Go module, npm package or Buf Schema Registry module dependencies, are resolved to directories which
+// are returned as additional proto_path entries to pass to protoc. Imports that cannot be resolved are reported
+// with an *UnresolvedImportsError, returned along with the resolved proto_path entries since protoc may still find
+// them in ways not modeled here.
+func FetchIncludes(protos []string, config Config) ([]string, error) {
+	paths := config.Paths
+	store := includeStore{dir: config.Dir, cacheDir: config.CacheDir}
+
+	// Files are resolved the same way as protoc will when invoked, with the includes directory, if any, and current
+	// directory added after user paths.
+	roots := Paths{ProtoPaths: append([]string{}, paths.ProtoPaths...)}
+	if store.dir != "" {
+		roots.ProtoPaths = append(roots.ProtoPaths, store.dir)
+	}
+	roots.ProtoPaths = append(roots.ProtoPaths, ".")
+
+	var queue []string
 	for _, proto := range protos {
-		if err := fetchInclude(proto, dir); err != nil {
-			return err
+		path, ok := roots.findInput(proto)
+		if !ok {
+			provided, err := paths.inDescriptorSets(proto)
+			if err != nil {
+				return nil, err
+			}
+			if provided {
+				continue
+			}
+			return nil, fmt.Errorf("%s: file not found in current directory, --proto_path or --descriptor_set_in", proto)
+		}
+		queue = append(queue, path)
+	}
+
+	var resolvers []rootResolver
+	if config.GoModDownload != nil {
+		goMod, err := FindUp(".", "go.mod")
+		if err != nil {
+			return nil, err
+		}
+		if goMod != "" {
+			goMods, err := readGoModules(goMod, config.GoModDownload)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", goMod, err)
+			}
+			resolvers = append(resolvers, goMods)
+		}
+	}
+	bufYAML, err := FindUp(".", "buf.yaml")
+	if err != nil {
+		return nil, err
+	}
+	if bufYAML != "" {
+		bsrMods, err := readBSRModules(bufYAML, store.bsrDir(), config.BSR)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", bufYAML, err)
+		}
+		resolvers = append(resolvers, bsrMods)
+	}
+	// npm is last since installing the dependencies of a package.json is the most expensive.
+	if config.NpmInstall != nil {
+		packageJSON, err := FindUp(".", "package.json")
+		if err != nil {
+			return nil, err
+		}
+		if packageJSON != "" {
+			npmPkgs, err := readNpmPackages(packageJSON, config.NpmInstall)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", packageJSON, err)
+			}
+			resolvers = append(resolvers, npmPkgs)
+		}
+	}
+
+	var extraRoots []string
+	var unresolved []UnresolvedImport
+
+	client := getter.Client{}
+	ctx := context.Background()
+	seen := map[string]bool{}
+	for len(queue) > 0 {
+		proto := filepath.Clean(queue[0])
+		queue = queue[1:]
+		if seen[proto] {
+			continue
 		}
+		seen[proto] = true
+
+		imports, err := scanImports(proto)
+		if err != nil {
+			return nil, err
+		}
+		for _, stmt := range imports {
+			imp := stmt.path
+			root, err := fetchInclude(ctx, &client, imp, store)
+			if err != nil {
+				return nil, err
+			}
+			if root != "" && root != store.dir && !contains(extraRoots, root) {
+				extraRoots = append(extraRoots, root)
+				roots.ProtoPaths = append(roots.ProtoPaths, root)
+			}
+			path, ok := roots.find(imp)
+			// Well-known types are bundled with protoc, so dependencies are not downloaded looking for them.
+			if !ok && strings.HasPrefix(imp, "google/protobuf/") {
+				continue
+			}
+			for _, r := range resolvers {
+				if ok {
+					break
+				}
+				root, err := r.root(imp)
+				if err != nil {
+					return nil, err
+				}
+				if root != "" && !contains(extraRoots, root) {
+					extraRoots = append(extraRoots, root)
+					roots.ProtoPaths = append(roots.ProtoPaths, root)
+					path, ok = roots.find(imp)
+				}
+			}
+			if ok {
+				queue = append(queue, path)
+				continue
+			}
+
+			provided, err := paths.inDescriptorSets(imp)
+			if err != nil {
+				return nil, err
+			}
+			if !provided {
+				unresolved = append(unresolved, UnresolvedImport{
+					File:   proto,
+					Line:   stmt.line,
+					Import: imp,
+					Hint:   suggest(imp, store),
+				})
+			}
+		}
+	}
+
+	if len(unresolved) > 0 {
+		return extraRoots, &UnresolvedImportsError{Imports: unresolved}
 	}
 
-	return nil
+	return extraRoots, nil
 }
 
-func fetchInclude(proto string, dir string) error {
+// importStmt is an import statement within a proto file.
+type importStmt struct {
+	path string
+	line int
+}
+
+func scanImports(proto string) ([]importStmt, error) {
 	f, err := os.Open(proto)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer f.Close()
 	// It would be simpler to use a structured parse, but protoc does not seem to allow it with missing imports.
 	// This regex should work well enough.
 	// https://github.com/protocolbuffers/protobuf/issues/10310
+	var imports []importStmt
 	s := bufio.NewScanner(f)
-	client := getter.Client{}
-	ctx := context.Background()
-	for s.Scan() {
+	for line := 1; s.Scan(); line++ {
 		if m := importRe.FindStringSubmatch(s.Text()); len(m) > 0 {
-			for _, includeSpec := range includeSpecs {
-				if strings.HasPrefix(m[1], includeSpec.prefix) {
-					dst := filepath.Join(dir, includeSpec.dir)
-
-					if _, err := os.Stat(dst); err == nil {
-						continue
-					}
-
-					repoParts := strings.Split(includeSpec.repo, "/")
-					repoName := repoParts[len(repoParts)-1]
-
-					url := fmt.Sprintf("https://%s/archive/refs/heads/%s.zip//%s-%s/%s?depth=1", includeSpec.repo, includeSpec.ref, repoName, includeSpec.ref, includeSpec.repoDir)
-					if _, err := client.Get(ctx, &getter.Request{
-						Src:     url,
-						Dst:     dst,
-						Umask:   0022,
-						GetMode: getter.ModeAny,
-					}); err != nil {
-						return err
-					}
-				}
-			}
+			imports = append(imports, importStmt{path: m[1], line: line})
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", proto, err)
+	}
+
+	return imports, nil
+}
+
+// fetchInclude downloads the include spec matching imp if needed, returning the proto_path it is downloaded under
+// or an empty string if no spec matches.
+func fetchInclude(ctx context.Context, client *getter.Client, imp string, store includeStore) (string, error) {
+	for _, includeSpec := range includeSpecs {
+		if strings.HasPrefix(imp, includeSpec.prefix) {
+			return store.ensure(ctx, client, includeSpec, false)
 		}
 	}
 
-	return nil
+	return "", nil
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,233 @@
+package proto
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/module"
+)
+
+func TestFetchIncludesUnresolved(t *testing.T) {
+	dir := t.TempDir()
+	api := filepath.Join(dir, "api")
+	includesDir := filepath.Join(dir, "includes")
+	// Existing include directories are not fetched again.
+	require.NoError(t, os.MkdirAll(filepath.Join(includesDir, "validate"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(api, "acme", "v1"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(api, "acme", "v1", "service.proto"), []byte(`syntax = "proto3";
+
+package acme.v1;
+
+import "acme/v1/types.proto";
+import "google/protobuf/empty.proto";
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(api, "acme", "v1", "types.proto"), []byte(`syntax = "proto3";
+
+import "validate/missing.proto";
+import "google/cloud/location.proto";
+import public "github.com/acme/shared/proto/money.proto";
+import "other/other.proto";
+`), 0644))
+
+	_, err := FetchIncludes([]string{"acme/v1/service.proto"}, Config{
+		Paths: Paths{ProtoPaths: []string{api}},
+		Dir:   includesDir,
+	})
+	var unresolvedErr *UnresolvedImportsError
+	require.True(t, errors.As(err, &unresolvedErr), "%v", err)
+
+	types := filepath.Join(api, "acme", "v1", "types.proto")
+	require.Equal(t, []UnresolvedImport{
+		{
+			File:   types,
+			Line:   3,
+			Import: "validate/missing.proto",
+			Hint:   "validate is fetched from github.com/envoyproxy/protoc-gen-validate at main into " + filepath.Join(includesDir, "validate") + " but does not contain this file, it may have moved upstream",
+		},
+		{
+			File:   types,
+			Line:   4,
+			Import: "google/cloud/location.proto",
+			Hint:   "it may be provided by buf.build/googleapis/googleapis, add it to the deps of buf.yaml",
+		},
+		{
+			File:   types,
+			Line:   5,
+			Import: "github.com/acme/shared/proto/money.proto",
+			Hint:   "if it is provided by a Go module, add it to go.mod, e.g. go get github.com/acme/shared",
+		},
+		{
+			File:   types,
+			Line:   6,
+			Import: "other/other.proto",
+			Hint:   "add the directory containing it with --proto_path",
+		},
+	}, unresolvedErr.Imports)
+}
+
+func TestFetchIncludesWellKnownTypesNotResolved(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{
+  "name": "app",
+  "dependencies": {"@acme/protos": "^1.0.0"}
+}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "service.proto"), []byte(`syntax = "proto3";
+
+import "google/protobuf/timestamp.proto";
+`), 0644))
+	chdir(t, dir)
+
+	installs := 0
+	roots, err := FetchIncludes([]string{"service.proto"}, Config{
+		NpmInstall: func(string) (string, error) {
+			installs++
+			return filepath.Join(dir, "node_modules"), nil
+		},
+	})
+	require.NoError(t, err)
+	require.Empty(t, roots)
+	require.Zero(t, installs)
+}
+
+func TestFetchIncludesNpmLast(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{
+  "name": "app",
+  "dependencies": {"@acme/protos": "^1.0.0"}
+}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(`module example.com/app
+
+go 1.21
+
+require github.com/acme/shared v1.0.0
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "service.proto"), []byte(`syntax = "proto3";
+
+import "github.com/acme/shared/money.proto";
+`), 0644))
+	modDir := filepath.Join(dir, "gomodcache", "github.com", "acme", "shared@v1.0.0")
+	require.NoError(t, os.MkdirAll(modDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modDir, "money.proto"), []byte(`syntax = "proto3";`), 0644))
+	chdir(t, dir)
+
+	installs := 0
+	_, err := FetchIncludes([]string{"service.proto"}, Config{
+		GoModDownload: func(module.Version) (string, error) {
+			return modDir, nil
+		},
+		NpmInstall: func(string) (string, error) {
+			installs++
+			return filepath.Join(dir, "node_modules"), nil
+		},
+	})
+	require.NoError(t, err)
+	require.Zero(t, installs)
+}
+
+func TestFetchIncludesUnresolvedKeepsRoots(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(`module example.com/app
+
+go 1.21
+
+require github.com/acme/shared v1.0.0
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "service.proto"), []byte(`syntax = "proto3";
+
+import "github.com/acme/shared/money.proto";
+import "other/other.proto";
+`), 0644))
+	modDir := filepath.Join(dir, "gomodcache", "github.com", "acme", "shared@v1.0.0")
+	require.NoError(t, os.MkdirAll(modDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modDir, "money.proto"), []byte(`syntax = "proto3";`), 0644))
+	chdir(t, dir)
+
+	// protoc may still find the unresolved import, so it needs the roots that were resolved.
+	roots, err := FetchIncludes([]string{"service.proto"}, Config{
+		GoModDownload: func(module.Version) (string, error) {
+			return modDir, nil
+		},
+	})
+	var unresolvedErr *UnresolvedImportsError
+	require.True(t, errors.As(err, &unresolvedErr), "%v", err)
+	require.Len(t, unresolvedErr.Imports, 1)
+	require.Equal(t, "other/other.proto", unresolvedErr.Imports[0].Import)
+	require.NotEmpty(t, roots)
+}
+
+// chdir changes the current directory for the duration of the test, since FetchIncludes finds project files from
+// it.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		require.NoError(t, os.Chdir(wd))
+	})
+}
+
+func TestFetchIncludesBSROnlyForMissingImports(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"contents": []interface{}{
+				map[string]interface{}{
+					"commit": map[string]string{"id": "28151c0d0a1641bf938a7672c500e01d"},
+					"files": []interface{}{
+						map[string]interface{}{"path": "acme/v1/acme.proto", "content": []byte("syntax = \"proto3\";")},
+					},
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	// Without a buf.lock, every run would request the latest commit.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "buf.yaml"), []byte(`version: v1
+deps:
+  - buf.build/googleapis/googleapis
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{
+  "name": "app",
+  "dependencies": {"@acme/protos": "^1.0.0"}
+}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "service.proto"), []byte(`syntax = "proto3";
+
+import "types.proto";
+import "google/protobuf/timestamp.proto";
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "types.proto"), []byte(`syntax = "proto3";`), 0644))
+	chdir(t, dir)
+
+	installs := 0
+	config := Config{
+		CacheDir: filepath.Join(dir, "cache"),
+		NpmInstall: func(string) (string, error) {
+			installs++
+			return filepath.Join(dir, "node_modules"), nil
+		},
+		BSR: BSRConfig{URL: srv.URL},
+	}
+	_, err := FetchIncludes([]string{"service.proto"}, config)
+	require.NoError(t, err)
+	require.Zero(t, requests)
+
+	// A missing import is downloaded from the BSR before trying npm packages.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "api.proto"), []byte(`syntax = "proto3";
+
+import "acme/v1/acme.proto";
+`), 0644))
+	_, err = FetchIncludes([]string{"api.proto"}, config)
+	require.NoError(t, err)
+	require.Equal(t, 1, requests)
+	require.Zero(t, installs)
+}
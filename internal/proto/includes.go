@@ -1,6 +1,30 @@
 package proto
 
+// includeSpecs is the built-in catalog of well-known proto sources. Entries are matched by import prefix and
+// fetched from the GitHub archive of ref, which is a release tag where the upstream repository publishes them and
+// otherwise its default branch. Multiple prefixes may share a dir, in which case it is only fetched once.
 var includeSpecs = []includeSpec{
+	{
+		prefix:  "buf/validate/",
+		repo:    "github.com/bufbuild/protovalidate",
+		ref:     "main",
+		repoDir: "proto/protovalidate/buf/validate",
+		dir:     "buf/validate",
+	},
+	{
+		prefix:  "cosmos_proto/",
+		repo:    "github.com/cosmos/cosmos-proto",
+		ref:     "v1.0.0-beta.3",
+		repoDir: "proto/cosmos_proto",
+		dir:     "cosmos_proto",
+	},
+	{
+		prefix:  "envoy/",
+		repo:    "github.com/envoyproxy/data-plane-api",
+		ref:     "main",
+		repoDir: "envoy",
+		dir:     "envoy",
+	},
 	{
 		prefix:  "google/api/",
 		repo:    "github.com/googleapis/googleapis",
@@ -8,6 +32,13 @@ var includeSpecs = []includeSpec{
 		repoDir: "google",
 		dir:     "google",
 	},
+	{
+		prefix:  "google/longrunning/",
+		repo:    "github.com/googleapis/googleapis",
+		ref:     "master",
+		repoDir: "google",
+		dir:     "google",
+	},
 	{
 		prefix:  "google/rpc/",
 		repo:    "github.com/googleapis/googleapis",
@@ -15,6 +46,13 @@ var includeSpecs = []includeSpec{
 		repoDir: "google",
 		dir:     "google",
 	},
+	{
+		prefix:  "google/type/",
+		repo:    "github.com/googleapis/googleapis",
+		ref:     "master",
+		repoDir: "google",
+		dir:     "google",
+	},
 	{
 		prefix:  "gogoproto/",
 		repo:    "github.com/gogo/protobuf",
@@ -22,6 +60,13 @@ var includeSpecs = []includeSpec{
 		repoDir: "gogoproto",
 		dir:     "gogoproto",
 	},
+	{
+		prefix:  "grpc/health/v1/",
+		repo:    "github.com/grpc/grpc-proto",
+		ref:     "master",
+		repoDir: "grpc/health",
+		dir:     "grpc/health",
+	},
 	{
 		prefix: "k8s.io/api/",
 		repo:   "github.com/kubernetes/api",
@@ -34,6 +79,27 @@ var includeSpecs = []includeSpec{
 		ref:    "master",
 		dir:    "k8s.io/apimachinery",
 	},
+	{
+		prefix:  "opentelemetry/proto/",
+		repo:    "github.com/open-telemetry/opentelemetry-proto",
+		ref:     "v1.0.0",
+		repoDir: "opentelemetry/proto",
+		dir:     "opentelemetry/proto",
+	},
+	{
+		prefix:  "protoc-gen-openapiv2/options/",
+		repo:    "github.com/grpc-ecosystem/grpc-gateway",
+		ref:     "v2.16.0",
+		repoDir: "protoc-gen-openapiv2/options",
+		dir:     "protoc-gen-openapiv2/options",
+	},
+	{
+		prefix:  "udpa/",
+		repo:    "github.com/cncf/xds",
+		ref:     "main",
+		repoDir: "udpa",
+		dir:     "udpa",
+	},
 	{
 		prefix:  "validate/",
 		repo:    "github.com/envoyproxy/protoc-gen-validate",
@@ -41,4 +107,11 @@ var includeSpecs = []includeSpec{
 		repoDir: "validate",
 		dir:     "validate",
 	},
+	{
+		prefix:  "xds/",
+		repo:    "github.com/cncf/xds",
+		ref:     "main",
+		repoDir: "xds",
+		dir:     "xds",
+	},
 }
@@ -0,0 +1,90 @@
+package proto
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// NpmInstaller installs the dependencies declared by a package.json, and its lockfile if present, returning the
+// node_modules directory containing them.
+type NpmInstaller func(packageJSON string) (string, error)
+
+// npmProtoDirs are the directories within a package, in order, that are used as proto_path roots. Packages
+// commonly ship protos under a top-level proto directory rather than at their root.
+var npmProtoDirs = []string{"proto", "protos", ""}
+
+// npmPackages are the dependencies declared by a package.json, used to resolve imports of protos shipped inside
+// npm packages such as @acme/protos at the versions the frontend depends on.
+type npmPackages struct {
+	install     NpmInstaller
+	packageJSON string
+
+	names []string
+
+	// nodeModules is set after dependencies have been installed.
+	nodeModules string
+}
+
+func readNpmPackages(path string, install NpmInstaller) (*npmPackages, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg struct {
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	}
+	if err := json.Unmarshal(b, &pkg); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var names []string
+	for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies, pkg.OptionalDependencies} {
+		for name := range deps {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return &npmPackages{
+		install:     install,
+		packageJSON: path,
+		names:       names,
+	}, nil
+}
+
+// root returns the proto directory of the package providing imp. Dependencies are only installed the first time
+// an import needs to be resolved. An empty string is returned if no package provides imp.
+func (p *npmPackages) root(imp string) (string, error) {
+	if len(p.names) == 0 {
+		return "", nil
+	}
+
+	if p.nodeModules == "" {
+		dir, err := p.install(p.packageJSON)
+		if err != nil {
+			return "", fmt.Errorf("installing npm dependencies of %s: %w", p.packageJSON, err)
+		}
+		p.nodeModules = dir
+	}
+
+	for _, name := range p.names {
+		for _, sub := range npmProtoDirs {
+			root := filepath.Join(p.nodeModules, filepath.FromSlash(name), sub)
+			if fileExists(filepath.Join(root, filepath.FromSlash(imp))) {
+				return root, nil
+			}
+		}
+	}
+
+	return "", nil
+}
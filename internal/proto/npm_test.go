@@ -0,0 +1,51 @@
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNpmPackagesRoot(t *testing.T) {
+	dir := t.TempDir()
+	packageJSON := filepath.Join(dir, "package.json")
+	require.NoError(t, os.WriteFile(packageJSON, []byte(`{
+  "name": "app",
+  "dependencies": {"@acme/protos": "^1.0.0", "react": "^18.0.0"},
+  "devDependencies": {"@acme/dev-protos": "^1.0.0"}
+}`), 0644))
+
+	nodeModules := filepath.Join(dir, "cache", "node_modules")
+	for _, f := range []string{
+		filepath.Join("@acme", "protos", "proto", "acme", "money", "v1", "money.proto"),
+		filepath.Join("@acme", "dev-protos", "acme", "dev", "v1", "dev.proto"),
+	} {
+		path := filepath.Join(nodeModules, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	installs := 0
+	pkgs, err := readNpmPackages(packageJSON, func(path string) (string, error) {
+		require.Equal(t, packageJSON, path)
+		installs++
+		return nodeModules, nil
+	})
+	require.NoError(t, err)
+
+	root, err := pkgs.root("acme/money/v1/money.proto")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(nodeModules, "@acme", "protos", "proto"), root)
+
+	root, err = pkgs.root("acme/dev/v1/dev.proto")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(nodeModules, "@acme", "dev-protos"), root)
+
+	root, err = pkgs.root("acme/other/v1/other.proto")
+	require.NoError(t, err)
+	require.Empty(t, root)
+
+	require.Equal(t, 1, installs)
+}
@@ -0,0 +1,147 @@
+package proto
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Paths are the locations protoc looks up input and imported files in, as parsed from its command line.
+type Paths struct {
+	ProtoPaths      []string
+	DescriptorSetIn []string
+}
+
+// ParsePaths extracts --proto_path (-I) and --descriptor_set_in values from protoc arguments. Each flag may
+// be repeated and may contain multiple entries separated by the OS path list separator, as with protoc.
+func ParsePaths(args []string) Paths {
+	var paths Paths
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var dst *[]string
+		var val string
+		switch {
+		case arg == "-I" || arg == "--proto_path" || arg == "--descriptor_set_in":
+			if i+1 >= len(args) {
+				continue
+			}
+			i++
+			val = args[i]
+			if arg == "--descriptor_set_in" {
+				dst = &paths.DescriptorSetIn
+			} else {
+				dst = &paths.ProtoPaths
+			}
+		case strings.HasPrefix(arg, "--proto_path="):
+			dst = &paths.ProtoPaths
+			val = strings.TrimPrefix(arg, "--proto_path=")
+		case strings.HasPrefix(arg, "--descriptor_set_in="):
+			dst = &paths.DescriptorSetIn
+			val = strings.TrimPrefix(arg, "--descriptor_set_in=")
+		case strings.HasPrefix(arg, "-I"):
+			dst = &paths.ProtoPaths
+			val = strings.TrimPrefix(arg, "-I")
+		default:
+			continue
+		}
+		for _, p := range filepath.SplitList(val) {
+			if p != "" {
+				*dst = append(*dst, p)
+			}
+		}
+	}
+
+	return paths
+}
+
+// findInput returns the on-disk path of an input file passed to protoc. As with protoc, a name that exists
+// relative to the current directory is used as is, otherwise it is resolved against each proto_path.
+func (p Paths) findInput(name string) (string, bool) {
+	if fileExists(name) {
+		return name, true
+	}
+
+	return p.find(name)
+}
+
+// find returns the on-disk path of a proto referenced by its virtual name, for example in an import, by checking
+// each proto_path in order. proto_path entries may map a virtual prefix to a disk directory with the form
+// virtual=disk.
+func (p Paths) find(name string) (string, bool) {
+	name = filepath.ToSlash(filepath.Clean(name))
+	for _, root := range p.ProtoPaths {
+		virtual, disk, ok := strings.Cut(root, "=")
+		if !ok {
+			disk = root
+			virtual = ""
+		}
+		rel := name
+		if virtual != "" {
+			virtual = strings.TrimSuffix(filepath.ToSlash(virtual), "/") + "/"
+			if !strings.HasPrefix(name, virtual) {
+				continue
+			}
+			rel = strings.TrimPrefix(name, virtual)
+		}
+		if path := filepath.Join(disk, filepath.FromSlash(rel)); fileExists(path) {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// inDescriptorSets returns whether a proto referenced by name is provided by any of the --descriptor_set_in
+// files. Such files already carry their resolved imports so do not need to be scanned.
+func (p Paths) inDescriptorSets(name string) (bool, error) {
+	name = filepath.ToSlash(filepath.Clean(name))
+	for _, path := range p.DescriptorSetIn {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		var set descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(b, &set); err != nil {
+			return false, err
+		}
+		for _, f := range set.GetFile() {
+			if f.GetName() == name {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// FindUp returns the path to the file with name in dir or its closest parent containing it, or an empty string if
+// there is none.
+func FindUp(dir string, name string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
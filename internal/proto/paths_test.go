@@ -0,0 +1,79 @@
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestParsePaths(t *testing.T) {
+	sep := string(filepath.ListSeparator)
+	paths := ParsePaths([]string{
+		"-I", "api",
+		"-Ithird_party",
+		"--proto_path=a" + sep + "b",
+		"--proto_path", "c",
+		"--descriptor_set_in=deps.pb",
+		"--go_out=out",
+		"foo/v1/foo.proto",
+	})
+	require.Equal(t, []string{"api", "third_party", "a", "b", "c"}, paths.ProtoPaths)
+	require.Equal(t, []string{"deps.pb"}, paths.DescriptorSetIn)
+}
+
+func TestPathsFind(t *testing.T) {
+	dir := t.TempDir()
+	api := filepath.Join(dir, "api")
+	require.NoError(t, os.MkdirAll(filepath.Join(api, "foo", "v1"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(api, "foo", "v1", "foo.proto"), nil, 0644))
+
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{{Name: proto.String("bar/v1/bar.proto")}},
+	})
+	require.NoError(t, err)
+	setPath := filepath.Join(dir, "deps.pb")
+	require.NoError(t, os.WriteFile(setPath, set, 0644))
+
+	paths := Paths{
+		ProtoPaths:      []string{filepath.Join(dir, "missing"), api, "acme=" + api},
+		DescriptorSetIn: []string{setPath},
+	}
+
+	path, ok := paths.findInput("foo/v1/foo.proto")
+	require.True(t, ok)
+	require.Equal(t, filepath.Join(api, "foo", "v1", "foo.proto"), path)
+
+	path, ok = paths.findInput("acme/foo/v1/foo.proto")
+	require.True(t, ok)
+	require.Equal(t, filepath.Join(api, "foo", "v1", "foo.proto"), path)
+
+	_, ok = paths.findInput("bar/v1/bar.proto")
+	require.False(t, ok)
+	provided, err := paths.inDescriptorSets("bar/v1/bar.proto")
+	require.NoError(t, err)
+	require.True(t, provided)
+
+	provided, err = paths.inDescriptorSets("baz/v1/baz.proto")
+	require.NoError(t, err)
+	require.False(t, provided)
+}
+
+func TestFindUp(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "a", "b")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a", "go.mod"), nil, 0644))
+
+	path, err := FindUp(nested, "go.mod")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "a", "go.mod"), path)
+
+	path, err = FindUp(nested, "protog-missing-file")
+	require.NoError(t, err)
+	require.Empty(t, path)
+}
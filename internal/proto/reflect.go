@@ -0,0 +1,168 @@
+package proto
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// ReflectFormatProto writes reflected files as .proto sources.
+	ReflectFormatProto = "proto"
+	// ReflectFormatDescriptorSet writes reflected files as a single FileDescriptorSet.
+	ReflectFormatDescriptorSet = "descriptor_set"
+)
+
+// ReflectConfig configures FetchReflection.
+type ReflectConfig struct {
+	// Target is the address of the server, e.g. localhost:8080.
+	Target string
+	// Plaintext disables TLS when connecting to the server.
+	Plaintext bool
+	// Format is the output format, either ReflectFormatProto or ReflectFormatDescriptorSet.
+	Format string
+	// Dir is the directory output is written to, normally the includes directory.
+	Dir string
+}
+
+// reflectionServices are the services implementing reflection itself, which are not useful to fetch.
+var reflectionServices = map[string]bool{
+	"grpc.reflection.v1.ServerReflection":      true,
+	"grpc.reflection.v1alpha.ServerReflection": true,
+}
+
+// FetchReflection downloads the file descriptors of all services exposed by a server using the gRPC server
+// reflection protocol, trying v1 and falling back to v1alpha. As .proto sources, files are written under the
+// configured directory with their import paths so they can be used as any other include. As a descriptor set, a
+// single file suitable for --descriptor_set_in is written, and its path returned.
+func FetchReflection(ctx context.Context, config ReflectConfig) ([]string, error) {
+	creds := credentials.NewTLS(&tls.Config{})
+	if config.Plaintext {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.DialContext(ctx, config.Target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", config.Target, err)
+	}
+	defer conn.Close()
+
+	client := grpcreflect.NewClientAuto(ctx, conn)
+	defer client.Reset()
+
+	services, err := client.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("listing services of %s: %w", config.Target, err)
+	}
+
+	var files []*desc.FileDescriptor
+	seen := map[string]bool{}
+	var add func(fd *desc.FileDescriptor)
+	// Dependencies are added before their dependents as required for a descriptor set.
+	add = func(fd *desc.FileDescriptor) {
+		if seen[fd.GetName()] {
+			return
+		}
+		seen[fd.GetName()] = true
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+		files = append(files, fd)
+	}
+	sort.Strings(services)
+	for _, svc := range services {
+		if reflectionServices[svc] {
+			continue
+		}
+		sd, err := client.ResolveService(svc)
+		if err != nil {
+			return nil, fmt.Errorf("resolving service %s: %w", svc, err)
+		}
+		add(sd.GetFile())
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s does not expose any services", config.Target)
+	}
+
+	switch config.Format {
+	case ReflectFormatProto, "":
+		return writeProtos(files, config.Dir)
+	case ReflectFormatDescriptorSet:
+		path, err := writeDescriptorSet(files, config.Dir, config.Target)
+		if err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", config.Format)
+	}
+}
+
+func writeProtos(files []*desc.FileDescriptor, dir string) ([]string, error) {
+	var written []string
+	p := protoprint.Printer{}
+	for _, fd := range files {
+		// Names come from the server, so make sure they cannot be written outside of dir.
+		name := path.Clean(fd.GetName())
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("invalid file name from server: %s", fd.GetName())
+		}
+		// Well-known types are bundled with protoc and should not be shadowed.
+		if strings.HasPrefix(name, "google/protobuf/") {
+			continue
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.Create(dst)
+		if err != nil {
+			return nil, err
+		}
+		err = p.PrintProtoFile(fd, f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("writing %s: %w", dst, err)
+		}
+		written = append(written, dst)
+	}
+
+	return written, nil
+}
+
+func writeDescriptorSet(files []*desc.FileDescriptor, dir string, target string) (string, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range files {
+		set.File = append(set.File, fd.AsFileDescriptorProto())
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.NewReplacer(":", "_", "/", "_").Replace(target) + ".binpb"
+	path := filepath.Join(dir, "reflect", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,140 @@
+package proto
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	v1alphareflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestFetchReflection(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(s *grpc.Server)
+	}{
+		{
+			name: "v1",
+			register: func(s *grpc.Server) {
+				reflection.Register(s)
+			},
+		},
+		{
+			name: "v1alpha",
+			register: func(s *grpc.Server) {
+				v1alphareflectiongrpc.RegisterServerReflectionServer(s, reflection.NewServer(reflection.ServerOptions{Services: s}))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			s := grpc.NewServer()
+			healthpb.RegisterHealthServer(s, health.NewServer())
+			tt.register(s)
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			go func() {
+				_ = s.Serve(lis)
+			}()
+			defer s.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			dir := t.TempDir()
+			written, err := FetchReflection(ctx, ReflectConfig{
+				Target:    lis.Addr().String(),
+				Plaintext: true,
+				Format:    ReflectFormatProto,
+				Dir:       dir,
+			})
+			require.NoError(t, err)
+			healthProto := filepath.Join(dir, "grpc", "health", "v1", "health.proto")
+			require.Equal(t, []string{healthProto}, written)
+			content, err := os.ReadFile(healthProto)
+			require.NoError(t, err)
+			require.Contains(t, string(content), "service Health {")
+
+			written, err = FetchReflection(ctx, ReflectConfig{
+				Target:    lis.Addr().String(),
+				Plaintext: true,
+				Format:    ReflectFormatDescriptorSet,
+				Dir:       dir,
+			})
+			require.NoError(t, err)
+			require.Len(t, written, 1)
+			b, err := os.ReadFile(written[0])
+			require.NoError(t, err)
+			var set descriptorpb.FileDescriptorSet
+			require.NoError(t, protov2.Unmarshal(b, &set))
+			require.Len(t, set.GetFile(), 1)
+			require.Equal(t, "grpc/health/v1/health.proto", set.GetFile()[0].GetName())
+
+			paths := Paths{DescriptorSetIn: written}
+			provided, err := paths.inDescriptorSets("grpc/health/v1/health.proto")
+			require.NoError(t, err)
+			require.True(t, provided)
+		})
+	}
+}
+
+// servicesFunc advertises services to the reflection server without registering them.
+type servicesFunc func() map[string]grpc.ServiceInfo
+
+func (f servicesFunc) GetServiceInfo() map[string]grpc.ServiceInfo {
+	return f()
+}
+
+func TestFetchReflectionInvalidName(t *testing.T) {
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    protov2.String("../../evil.proto"),
+		Package: protov2.String("evil"),
+		Syntax:  protov2.String("proto3"),
+		Service: []*descriptorpb.ServiceDescriptorProto{{Name: protov2.String("Evil")}},
+	}, nil)
+	require.NoError(t, err)
+	files := &protoregistry.Files{}
+	require.NoError(t, files.RegisterFile(fd))
+
+	s := grpc.NewServer()
+	v1alphareflectiongrpc.RegisterServerReflectionServer(s, reflection.NewServer(reflection.ServerOptions{
+		Services: servicesFunc(func() map[string]grpc.ServiceInfo {
+			return map[string]grpc.ServiceInfo{"evil.Evil": {}}
+		}),
+		DescriptorResolver: files,
+	}))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dir := filepath.Join(t.TempDir(), "includes", "reflect")
+	_, err = FetchReflection(ctx, ReflectConfig{
+		Target:    lis.Addr().String(),
+		Plaintext: true,
+		Format:    ReflectFormatProto,
+		Dir:       dir,
+	})
+	require.ErrorContains(t, err, "invalid file name from server: ../../evil.proto")
+	_, err = os.Stat(filepath.Join(dir, "..", "..", "evil.proto"))
+	require.True(t, os.IsNotExist(err), "%v", err)
+}
@@ -0,0 +1,295 @@
+package proto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-getter/v2"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// includeStore is where includes matching includeSpecs are downloaded to.
+type includeStore struct {
+	// dir is the per-project directory all specs are downloaded into, if configured.
+	dir string
+	// cacheDir is the shared directory used when dir is not configured, where each repository and ref is
+	// downloaded to its own root so projects using the same version share a single copy.
+	cacheDir string
+}
+
+// includeRecord is written when an include is downloaded, to know what it was downloaded from and to be able to
+// verify its content later.
+type includeRecord struct {
+	Repo string `json:"repo"`
+	Ref  string `json:"ref"`
+	// Hash is the dirhash of the downloaded tree, in the same format as go.sum.
+	Hash string `json:"hash"`
+}
+
+// root returns the proto_path that a spec is downloaded under.
+func (st includeStore) root(s includeSpec) string {
+	if st.dir != "" {
+		return st.dir
+	}
+	return filepath.Join(st.cacheDir, filepath.FromSlash(s.repo), url.PathEscape(s.ref))
+}
+
+// bsrDir returns the directory Buf Schema Registry modules are downloaded to.
+func (st includeStore) bsrDir() string {
+	if st.dir != "" {
+		return filepath.Join(st.dir, ".bsr")
+	}
+	return filepath.Join(st.cacheDir, "bsr")
+}
+
+func (st includeStore) recordPath(s includeSpec) string {
+	return filepath.Join(st.root(s), ".protog", url.PathEscape(s.dir)+".json")
+}
+
+func (st includeStore) readRecord(s includeSpec) (*includeRecord, error) {
+	b, err := os.ReadFile(st.recordPath(s))
+	if err != nil {
+		return nil, err
+	}
+	var rec includeRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", st.recordPath(s), err)
+	}
+	return &rec, nil
+}
+
+// ensure downloads a spec unless it is already present from the same repository and ref, returning the proto_path
+// it is downloaded under. Trees without a record, for example from older versions of protog, are used as is. If
+// force is set, the spec is always downloaded again, replacing any existing tree.
+func (st includeStore) ensure(ctx context.Context, client *getter.Client, s includeSpec, force bool) (string, error) {
+	root := st.root(s)
+	dst := filepath.Join(root, s.dir)
+
+	_, statErr := os.Stat(dst)
+	exists := statErr == nil
+	if exists && !force {
+		rec, err := st.readRecord(s)
+		if errors.Is(err, fs.ErrNotExist) {
+			return root, nil
+		}
+		if err == nil && rec.Repo == s.repo && rec.Ref == s.ref {
+			return root, nil
+		}
+	}
+
+	if err := st.download(ctx, client, s); err != nil {
+		if exists && !force {
+			// The catalog has changed but continue to work offline with what was downloaded before.
+			fmt.Fprintf(os.Stderr, "warning: could not refresh %s, using existing files: %v\n", dst, err)
+			return root, nil
+		}
+		return "", err
+	}
+
+	return root, nil
+}
+
+func (st includeStore) download(ctx context.Context, client *getter.Client, s includeSpec) error {
+	dst := filepath.Join(st.root(s), s.dir)
+
+	// Download into a temporary directory that is moved into place once complete so an interrupted download,
+	// or one by a concurrent invocation, is not mistaken for a complete one.
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dst), "fetch-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	src := s.url()
+	tmpDst := filepath.Join(tmpDir, "include")
+	if _, err := client.Get(ctx, &getter.Request{
+		Src:     src,
+		Dst:     tmpDst,
+		Umask:   0022,
+		GetMode: getter.ModeAny,
+	}); err != nil {
+		return fmt.Errorf("fetching %s from %s: %w", s.prefix, src, err)
+	}
+
+	hash, err := dirhash.HashDir(tmpDst, "", dirhash.Hash1)
+	if err != nil {
+		return err
+	}
+
+	// Move any existing tree out of the way rather than deleting it first so it is only removed once the
+	// replacement is in place.
+	if _, err := os.Stat(dst); err == nil {
+		if err := os.Rename(dst, filepath.Join(tmpDir, "old")); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmpDst, dst); err != nil {
+		if _, serr := os.Stat(dst); serr != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(includeRecord{Repo: s.repo, Ref: s.ref, Hash: hash})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.recordPath(s)), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(st.recordPath(s), b, 0644)
+}
+
+// IncludeStatus describes an include tree managed by protog.
+type IncludeStatus struct {
+	// Prefixes are the import prefixes served by the tree.
+	Prefixes []string
+	// Repo is the repository the tree is downloaded from.
+	Repo string
+	// Ref is the ref the tree is downloaded from.
+	Ref string
+	// Path is the location of the tree.
+	Path string
+	// Hash is the recorded hash of the tree, empty if there is no record.
+	Hash string
+	// Problem describes why the tree was considered corrupted or stale by VerifyIncludes, empty if it was valid.
+	Problem string
+}
+
+// includeTree groups specs that are downloaded to the same location.
+type includeTree struct {
+	specs []includeSpec
+}
+
+func (st includeStore) trees() []includeTree {
+	var trees []includeTree
+	idx := map[string]int{}
+	for _, s := range includeSpecs {
+		key := filepath.Join(st.root(s), s.dir)
+		if i, ok := idx[key]; ok {
+			trees[i].specs = append(trees[i].specs, s)
+			continue
+		}
+		idx[key] = len(trees)
+		trees = append(trees, includeTree{specs: []includeSpec{s}})
+	}
+	return trees
+}
+
+func (st includeStore) status(t includeTree) IncludeStatus {
+	s := t.specs[0]
+	status := IncludeStatus{
+		Repo: s.repo,
+		Ref:  s.ref,
+		Path: filepath.Join(st.root(s), s.dir),
+	}
+	for _, s := range t.specs {
+		status.Prefixes = append(status.Prefixes, strings.TrimSuffix(s.prefix, "/"))
+	}
+	if rec, err := st.readRecord(s); err == nil {
+		status.Hash = rec.Hash
+	}
+	return status
+}
+
+// ListIncludes returns the include trees that have been downloaded. dir is the per-project includes directory, or
+// empty to use the shared cacheDir.
+func ListIncludes(dir string, cacheDir string) []IncludeStatus {
+	st := includeStore{dir: dir, cacheDir: cacheDir}
+	var res []IncludeStatus
+	for _, t := range st.trees() {
+		status := st.status(t)
+		if _, err := os.Stat(status.Path); err == nil {
+			res = append(res, status)
+		}
+	}
+	return res
+}
+
+// UpdateIncludes downloads include trees again to pick up upstream changes, for example on a branch. If prefix is
+// empty, all downloaded trees are updated, otherwise only those serving an import prefix starting with it, which
+// are downloaded even if not present yet.
+func UpdateIncludes(ctx context.Context, dir string, cacheDir string, prefix string) ([]IncludeStatus, error) {
+	st := includeStore{dir: dir, cacheDir: cacheDir}
+	client := &getter.Client{}
+	var res []IncludeStatus
+	for _, t := range st.trees() {
+		status := st.status(t)
+		if prefix == "" {
+			if _, err := os.Stat(status.Path); err != nil {
+				continue
+			}
+		} else if !t.matches(prefix) {
+			continue
+		}
+		if _, err := st.ensure(ctx, client, t.specs[0], true); err != nil {
+			return res, err
+		}
+		res = append(res, st.status(t))
+	}
+	if prefix != "" && len(res) == 0 {
+		return nil, fmt.Errorf("no include matches prefix %s", prefix)
+	}
+	return res, nil
+}
+
+// VerifyIncludes compares downloaded include trees against their recorded hashes, downloading again any that are
+// corrupted, missing a record, or stale because the catalog now refers to a different repository or ref. All trees
+// are returned, with Problem set for those that were downloaded again.
+func VerifyIncludes(ctx context.Context, dir string, cacheDir string) ([]IncludeStatus, error) {
+	st := includeStore{dir: dir, cacheDir: cacheDir}
+	client := &getter.Client{}
+	var res []IncludeStatus
+	for _, t := range st.trees() {
+		s := t.specs[0]
+		status := st.status(t)
+		if _, err := os.Stat(status.Path); err != nil {
+			continue
+		}
+
+		rec, err := st.readRecord(s)
+		switch {
+		case err != nil:
+			status.Problem = "no record of download"
+		case rec.Repo != s.repo || rec.Ref != s.ref:
+			status.Problem = fmt.Sprintf("downloaded from %s at %s", rec.Repo, rec.Ref)
+		default:
+			hash, err := dirhash.HashDir(status.Path, "", dirhash.Hash1)
+			if err != nil {
+				return res, err
+			}
+			if hash != rec.Hash {
+				status.Problem = fmt.Sprintf("hash mismatch, expected %s but found %s", rec.Hash, hash)
+			}
+		}
+
+		if status.Problem != "" {
+			if _, err := st.ensure(ctx, client, s, true); err != nil {
+				return res, err
+			}
+			problem := status.Problem
+			status = st.status(t)
+			status.Problem = problem
+		}
+		res = append(res, status)
+	}
+	return res, nil
+}
+
+func (t includeTree) matches(prefix string) bool {
+	for _, s := range t.specs {
+		if strings.HasPrefix(s.prefix, prefix) || strings.HasPrefix(prefix, s.prefix) {
+			return true
+		}
+	}
+	return false
+}
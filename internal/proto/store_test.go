@@ -0,0 +1,111 @@
+package proto
+
+import (
+	"archive/zip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludesUpdateAndVerify(t *testing.T) {
+	downloads := 0
+	content := "syntax = \"proto3\";"
+	// The handler runs on another goroutine, where require cannot stop the test.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !assert.Contains(t, []string{"/github.com/acme/protos/archive/main.zip", "/github.com/acme/protos/archive/v1.0.0.zip"}, r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			return
+		}
+		downloads++
+		zw := zip.NewWriter(w)
+		f, err := zw.Create("protos-main/acme/v1/acme.proto")
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = f.Write([]byte(content))
+		assert.NoError(t, err)
+		assert.NoError(t, zw.Close())
+	}))
+	defer srv.Close()
+
+	origScheme, origSpecs := archiveScheme, includeSpecs
+	defer func() {
+		archiveScheme, includeSpecs = origScheme, origSpecs
+	}()
+	archiveScheme = srv.URL + "/"
+	includeSpecs = []includeSpec{
+		{
+			prefix:  "acme/v1/",
+			repo:    "github.com/acme/protos",
+			ref:     "main",
+			repoDir: "acme",
+			dir:     "acme",
+		},
+	}
+
+	cacheDir := t.TempDir()
+	ctx := context.Background()
+	acmeProto := filepath.Join(cacheDir, "github.com", "acme", "protos", "main", "acme", "v1", "acme.proto")
+
+	require.Empty(t, ListIncludes("", cacheDir))
+	_, err := UpdateIncludes(ctx, "", cacheDir, "other")
+	require.Error(t, err)
+
+	statuses, err := UpdateIncludes(ctx, "", cacheDir, "acme")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, []string{"acme/v1"}, statuses[0].Prefixes)
+	require.NotEmpty(t, statuses[0].Hash)
+	require.FileExists(t, acmeProto)
+	require.Equal(t, statuses, ListIncludes("", cacheDir))
+	require.Equal(t, 1, downloads)
+
+	// Intact trees are not downloaded again.
+	statuses, err = VerifyIncludes(ctx, "", cacheDir)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Empty(t, statuses[0].Problem)
+	require.Equal(t, 1, downloads)
+
+	// Partially deleted trees are.
+	require.NoError(t, os.Remove(acmeProto))
+	statuses, err = VerifyIncludes(ctx, "", cacheDir)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Contains(t, statuses[0].Problem, "hash mismatch")
+	require.FileExists(t, acmeProto)
+	require.Equal(t, 2, downloads)
+
+	// Updating all trees picks up upstream changes.
+	content = "syntax = \"proto2\";"
+	statuses, err = UpdateIncludes(ctx, "", cacheDir, "")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	b, err := os.ReadFile(acmeProto)
+	require.NoError(t, err)
+	require.Equal(t, content, string(b))
+	require.Equal(t, 3, downloads)
+
+	// Per-project trees downloaded from a ref that is no longer in the catalog are stale.
+	dir := filepath.Join(cacheDir, "project")
+	_, err = UpdateIncludes(ctx, dir, "", "acme")
+	require.NoError(t, err)
+	require.Equal(t, 4, downloads)
+	includeSpecs[0].ref = "v1.0.0"
+	statuses, err = VerifyIncludes(ctx, dir, "")
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, "downloaded from github.com/acme/protos at main", statuses[0].Problem)
+	require.Equal(t, "v1.0.0", statuses[0].Ref)
+	require.Equal(t, 5, downloads)
+
+}
@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// versionConstraint is a range of versions such as ~1.28, ^0.8 or >=3.21 <4, with the same syntax as npm. Space
+// separated comparators must all match and || separates alternatives.
+type versionConstraint struct {
+	sets [][]func(v string) bool
+}
+
+// isVersionConstraint returns whether ver is a constraint rather than an exact version or dist-tag.
+func isVersionConstraint(ver string) bool {
+	ver = strings.TrimSpace(ver)
+	if ver == "" {
+		return false
+	}
+	return strings.ContainsAny(ver, "~^<>=| *") || strings.HasSuffix(ver, ".x") || strings.Contains(ver, ".x.")
+}
+
+func parseVersionConstraint(raw string) (*versionConstraint, error) {
+	c := &versionConstraint{}
+	for _, alt := range strings.Split(raw, "||") {
+		var set []func(string) bool
+		fields := strings.Fields(alt)
+		for i := 0; i < len(fields); i++ {
+			f := fields[i]
+			// Allow a space between the operator and version, e.g. >= 3.21.
+			if strings.Trim(f, "~^<>=") == "" && i+1 < len(fields) {
+				f += fields[i+1]
+				i++
+			}
+			fn, err := parseComparator(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %w", raw, err)
+			}
+			set = append(set, fn)
+		}
+		if len(set) == 0 {
+			return nil, fmt.Errorf("invalid version constraint %q: empty range", raw)
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+// partialVersion is a version with up to three components, where missing or wildcard components are -1.
+type partialVersion [3]int
+
+func parsePartialVersion(s string) (partialVersion, error) {
+	p := partialVersion{-1, -1, -1}
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "go"), "v")
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || s == "" {
+		return p, fmt.Errorf("invalid version %q", s)
+	}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid version %q", s)
+		}
+		p[i] = n
+	}
+	return p, nil
+}
+
+// semver returns the lowest version matching p.
+func (p partialVersion) semver() string {
+	var parts [3]int
+	for i, n := range p {
+		if n < 0 {
+			break
+		}
+		parts[i] = n
+	}
+	return fmt.Sprintf("v%d.%d.%d", parts[0], parts[1], parts[2])
+}
+
+// next returns the lowest version above all those matching p.
+func (p partialVersion) next() string {
+	switch {
+	case p[0] < 0:
+		return ""
+	case p[1] < 0:
+		return fmt.Sprintf("v%d.0.0", p[0]+1)
+	case p[2] < 0:
+		return fmt.Sprintf("v%d.%d.0", p[0], p[1]+1)
+	default:
+		return fmt.Sprintf("v%d.%d.%d", p[0], p[1], p[2]+1)
+	}
+}
+
+func between(lower, upper string) func(string) bool {
+	return func(v string) bool {
+		return (lower == "" || semver.Compare(v, lower) >= 0) && (upper == "" || semver.Compare(v, upper) < 0)
+	}
+}
+
+func parseComparator(s string) (func(string) bool, error) {
+	op := s[:len(s)-len(strings.TrimLeft(s, "~^<>="))]
+	p, err := parsePartialVersion(s[len(op):])
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "", "=":
+		return between(p.semver(), p.next()), nil
+	case "~":
+		if p[1] < 0 {
+			return between(p.semver(), p.next()), nil
+		}
+		return between(p.semver(), partialVersion{p[0], p[1], -1}.next()), nil
+	case "^":
+		switch {
+		case p[0] > 0 || p[1] < 0:
+			return between(p.semver(), partialVersion{p[0], -1, -1}.next()), nil
+		case p[1] > 0 || p[2] < 0:
+			return between(p.semver(), partialVersion{p[0], p[1], -1}.next()), nil
+		default:
+			return between(p.semver(), p.next()), nil
+		}
+	case ">=":
+		return between(p.semver(), ""), nil
+	case ">":
+		return between(p.next(), ""), nil
+	case "<":
+		return between("", p.semver()), nil
+	case "<=":
+		return between("", p.next()), nil
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+}
+
+// matches returns whether v, in canonical semver form, satisfies the constraint. Prereleases never match.
+func (c *versionConstraint) matches(v string) bool {
+	if semver.Prerelease(v) != "" {
+		return false
+	}
+	for _, set := range c.sets {
+		ok := true
+		for _, fn := range set {
+			if !fn(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeVersion converts a version as published, e.g. 1.2.3, v1.2.3 or go1.21, to semver for comparison.
+func normalizeVersion(v string) (string, bool) {
+	var sv string
+	if strings.HasPrefix(v, "go") {
+		sv = goSemver(v)
+	} else if strings.HasPrefix(v, "v") {
+		sv = v
+	} else {
+		sv = "v" + v
+	}
+	if !semver.IsValid(sv) {
+		return "", false
+	}
+	return sv, true
+}
+
+// highestVersion returns the highest of versions satisfying the constraint, or any release if it is nil, in the
+// form it was published.
+func highestVersion(versions []string, c *versionConstraint) (string, bool) {
+	var best, bestSemver string
+	for _, v := range versions {
+		sv, ok := normalizeVersion(v)
+		if !ok || semver.Prerelease(sv) != "" || (c != nil && !c.matches(sv)) {
+			continue
+		}
+		if best == "" || semver.Compare(sv, bestSemver) > 0 {
+			best, bestSemver = v, sv
+		}
+	}
+	return best, best != ""
+}
+
+// resolveConstraint resolves a version constraint for a tool to the highest available version satisfying it,
+// printing the chosen version.
+func resolveConstraint(name string, constraint string, versions func() ([]string, error)) (string, error) {
+	c, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	available, err := versions()
+	if err != nil {
+		return "", fmt.Errorf("listing versions of %s: %w", name, err)
+	}
+	v, ok := highestVersion(available, c)
+	if !ok {
+		return "", fmt.Errorf("no version of %s satisfies %s", name, constraint)
+	}
+	fmt.Fprintf(os.Stderr, "resolved %s %s to %s\n", name, constraint, v)
+	return v, nil
+}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionConstraint(t *testing.T) {
+	versions := []string{"0.7.9", "0.8.0", "0.8.5", "0.9.0", "1.27.3", "1.28.0", "1.28.4", "1.29.0-rc.1", "1.29.0", "2.0.0"}
+
+	tests := []struct {
+		constraint string
+		expected   string
+	}{
+		{constraint: "~1.28", expected: "1.28.4"},
+		{constraint: "~1.28.1", expected: "1.28.4"},
+		{constraint: "~1", expected: "1.29.0"},
+		{constraint: "^0.8", expected: "0.8.5"},
+		{constraint: "^1.27", expected: "1.29.0"},
+		{constraint: "^0.0.1"},
+		{constraint: ">=0.8 <1", expected: "0.9.0"},
+		{constraint: ">= 0.8 < 1", expected: "0.9.0"},
+		{constraint: ">1.28 <=1.29", expected: "1.29.0"},
+		{constraint: "<0.8 || ~1.27", expected: "1.27.3"},
+		{constraint: "1.28.x", expected: "1.28.4"},
+		{constraint: "*", expected: "2.0.0"},
+		{constraint: "=0.8.0", expected: "0.8.0"},
+		{constraint: ">=3"},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.constraint, func(t *testing.T) {
+			require.True(t, isVersionConstraint(tt.constraint))
+			c, err := parseVersionConstraint(tt.constraint)
+			require.NoError(t, err)
+			v, ok := highestVersion(versions, c)
+			require.Equal(t, tt.expected != "", ok)
+			require.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestVersionConstraintPublishedForms(t *testing.T) {
+	c, err := parseVersionConstraint("~1.21")
+	require.NoError(t, err)
+	v, ok := highestVersion([]string{"go1.20.14", "go1.21", "go1.21.6", "go1.22rc1", "go1.22.0"}, c)
+	require.True(t, ok)
+	require.Equal(t, "go1.21.6", v)
+
+	c, err = parseVersionConstraint(">=21 <23")
+	require.NoError(t, err)
+	v, ok = highestVersion([]string{"v21.12", "v22.5", "v23.0", "v22.0-rc1"}, c)
+	require.True(t, ok)
+	require.Equal(t, "v22.5", v)
+}
+
+func TestIsVersionConstraint(t *testing.T) {
+	for _, v := range []string{"", "1.21", "v1.2.3", "latest", "next", "21.5"} {
+		require.False(t, isVersionConstraint(v), v)
+	}
+}
+
+func TestParseVersionConstraintInvalid(t *testing.T) {
+	for _, c := range []string{"~", ">=a", "^1.2.3.4", "<1 ||"} {
+		_, err := parseVersionConstraint(c)
+		require.Error(t, err, c)
+	}
+}
+
+func TestFetchReleaseIndex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.json" {
+			http.Error(w, "<html>rate limited</html>", http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"version":"v20.9.0"}]`))
+	}))
+	defer srv.Close()
+
+	b, err := fetchReleaseIndex(srv.URL + "/index.json")
+	require.NoError(t, err)
+	require.Equal(t, `[{"version":"v20.9.0"}]`, string(b))
+
+	// An error page is reported with its status rather than failing to parse.
+	_, err = fetchReleaseIndex(srv.URL + "/other")
+	require.ErrorContains(t, err, "429 Too Many Requests")
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// environ returns the environment of this process, replaced in tests.
+var environ = os.Environ
+
+// PluginEnvVar returns the environment variable setting variables to run protoc with when using a tool, e.g.
+// PROTOG_ENV_PROTOC_GEN_TS for protoc-gen-ts.
+func PluginEnvVar(tool string) string {
+	return "PROTOG_ENV_" + strings.ToUpper(strings.ReplaceAll(tool, "-", "_"))
+}
+
+// passthroughEnv returns the variables of this process's environment matching EnvPassthrough.
+func (m *ToolManager) passthroughEnv() map[string]string {
+	res := map[string]string{}
+	if len(m.config.EnvPassthrough) == 0 {
+		return res
+	}
+	for _, e := range environ() {
+		k, v, ok := strings.Cut(e, "=")
+		if !ok || k == "" {
+			continue
+		}
+		for _, pattern := range m.config.EnvPassthrough {
+			if matchesEnvPattern(k, pattern) {
+				res[k] = v
+				break
+			}
+		}
+	}
+	return res
+}
+
+// matchesEnvPattern returns whether the variable key matches pattern, a name or, with a trailing *, a prefix.
+func matchesEnvPattern(key string, pattern string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(key, strings.TrimSuffix(pattern, "*"))
+	}
+	return key == pattern
+}
+
+// subprocessEnv returns the environment for running a tool with the commands in path, containing the passed through
+// variables, then those of extra, each taking precedence over the previous. path is placed before any PATH from
+// them.
+func (m *ToolManager) subprocessEnv(path []string, extra ...map[string]string) []string {
+	vars := m.passthroughEnv()
+	for _, e := range extra {
+		for k, v := range e {
+			vars[k] = v
+		}
+	}
+
+	full := append([]string{}, path...)
+	if p := vars["PATH"]; p != "" {
+		full = append(full, p)
+	}
+	delete(vars, "PATH")
+
+	env := make([]string, 0, len(vars)+1)
+	if len(full) > 0 {
+		env = append(env, fmt.Sprintf("PATH=%s", mergePath(full)))
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", k, vars[k]))
+	}
+	return env
+}
+
+// printEnv prints the environment a command is run with when verbose.
+func (m *ToolManager) printEnv(command string, env []string) {
+	if !m.config.Verbose {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "running %s with environment:\n", command)
+	for _, e := range env {
+		fmt.Fprintf(os.Stderr, "  %s\n", e)
+	}
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubprocessEnv(t *testing.T) {
+	environ = func() []string {
+		return []string{"HOME=/home/user", "LC_ALL=C", "LC_CTYPE=UTF-8", "LANG=en_US", "PATH=/usr/bin", "SECRET=shh"}
+	}
+	t.Cleanup(func() {
+		environ = os.Environ
+	})
+
+	tests := []struct {
+		name        string
+		passthrough []string
+		extra       []map[string]string
+		expected    []string
+	}{
+		{
+			name:     "hermetic",
+			expected: []string{"PATH=" + mergePath([]string{"/tools/protoc"})},
+		},
+		{
+			name:        "passthrough",
+			passthrough: []string{"HOME", "LC_*", "PATH"},
+			expected: []string{
+				"PATH=" + mergePath([]string{"/tools/protoc", "/usr/bin"}),
+				"HOME=/home/user",
+				"LC_ALL=C",
+				"LC_CTYPE=UTF-8",
+			},
+		},
+		{
+			name:        "extra",
+			passthrough: []string{"HOME"},
+			extra: []map[string]string{
+				{"HOME": "/tmp/home", "NODE_OPTIONS": "--a"},
+				{"NODE_OPTIONS": "--b"},
+			},
+			expected: []string{
+				"PATH=" + mergePath([]string{"/tools/protoc"}),
+				"HOME=/tmp/home",
+				"NODE_OPTIONS=--b",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			m := &ToolManager{config: Config{EnvPassthrough: tt.passthrough}}
+			require.Equal(t, tt.expected, m.subprocessEnv([]string{"/tools/protoc"}, tt.extra...))
+		})
+	}
+}
+
+func TestProtocEnv(t *testing.T) {
+	m := &ToolManager{config: Config{
+		PluginEnv: map[string]map[string]string{
+			"protoc-gen-es": {"NODE_OPTIONS": "--max-old-space-size=4096"},
+			"protoc-gen-go": {"GODEBUG": "x=1"},
+		},
+	}}
+	used := &toolset{path: []string{"/tools/es"}, versions: Versions{"protoc": "v25.0", "protoc-gen-es": "1.4.0"}}
+	require.Equal(t,
+		[]string{"PATH=" + mergePath([]string{"/tools/es"}), "NODE_OPTIONS=--max-old-space-size=4096"},
+		m.protocEnv(used))
+}
+
+func TestPluginEnvVar(t *testing.T) {
+	require.Equal(t, "PROTOG_ENV_PROTOC_GEN_TS", PluginEnvVar("protoc-gen-ts"))
+	require.Equal(t, "PROTOG_ENV_PROTOC", PluginEnvVar("protoc"))
+}
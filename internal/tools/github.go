@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// githubAPIURL is the base URL of the GitHub REST API, replaced in tests.
+var githubAPIURL = "https://api.github.com"
+
+var githubClient = &http.Client{Timeout: 30 * time.Second}
+
+// githubReleasePages bounds how many pages of releases are listed when looking for a matching tag.
+const githubReleasePages = 5
+
+// tagScheme describes how a plugin's releases are tagged in a repository that also has unrelated releases.
+type tagScheme struct {
+	// prefix is the part of the tag before the version, e.g. cmd/protoc-gen-go-grpc/.
+	prefix string
+	// pattern, if set, must match the version after the prefix, in addition to it being semver.
+	pattern *regexp.Regexp
+	// fromTags lists the repository's tags instead of its releases, for repositories that only tag versions.
+	fromTags bool
+}
+
+// version returns the version of a release tag, or false if the tag is not of this scheme or is not a release.
+func (t *tagScheme) version(tag string) (string, bool) {
+	if !strings.HasPrefix(tag, t.prefix) {
+		return "", false
+	}
+	v := strings.TrimPrefix(tag, t.prefix)
+	if t.pattern != nil && !t.pattern.MatchString(v) {
+		return "", false
+	}
+	sv := v
+	if !strings.HasPrefix(sv, "v") {
+		sv = "v" + sv
+	}
+	if !semver.IsValid(sv) || semver.Prerelease(sv) != "" {
+		return "", false
+	}
+	return v, true
+}
+
+// latestGitHubVersion returns the version of the latest release of a GitHub repository. Without a tag scheme, the
+// repository's own latest release is used.
+func (m *ToolManager) latestGitHubVersion(repo string, tags *tagScheme) (string, error) {
+	if tags == nil {
+		return determineLatestVersionForGitHubRepo(repo)
+	}
+	return m.latestGitHubRelease(repo, tags)
+}
+
+// latestGitHubRelease returns the highest version of the releases of a GitHub repository tagged with the scheme.
+func (m *ToolManager) latestGitHubRelease(repo string, tags *tagScheme) (string, error) {
+	versions, err := m.githubReleaseVersions(repo, tags)
+	if err != nil {
+		return "", err
+	}
+	v, _ := highestVersion(versions, nil)
+	return v, nil
+}
+
+// githubReleaseVersions lists the releases of a GitHub repository and returns the versions of those tagged with
+// the scheme, skipping drafts and prereleases. Requests are authenticated with the configured token, if any, to
+// avoid the low rate limit of anonymous requests.
+func (m *ToolManager) githubReleaseVersions(repo string, tags *tagScheme) ([]string, error) {
+	if tags == nil {
+		tags = &tagScheme{}
+	}
+	ownerRepo := strings.TrimPrefix(repo, "github.com/")
+
+	var versions []string
+	for page := 1; page <= githubReleasePages; page++ {
+		u := fmt.Sprintf("%s/repos/%s/releases?per_page=100&page=%d", githubAPIURL, ownerRepo, page)
+		if tags.fromTags {
+			u = fmt.Sprintf("%s/repos/%s/git/matching-refs/tags/%s", githubAPIURL, ownerRepo, tags.prefix)
+		}
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if m.config.GitHubToken != "" {
+			req.Header.Set("Authorization", "Bearer "+m.config.GitHubToken)
+		}
+
+		resp, err := githubClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("listing releases of %s: %w", repo, err)
+		}
+		// Releases have a tag name and tags a ref.
+		var releases []struct {
+			TagName    string `json:"tag_name"`
+			Ref        string `json:"ref"`
+			Draft      bool   `json:"draft"`
+			Prerelease bool   `json:"prerelease"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("listing releases of %s: %s", repo, resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&releases)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parsing releases of %s: %w", repo, err)
+		}
+
+		for _, r := range releases {
+			if r.Draft || r.Prerelease {
+				continue
+			}
+			tag := r.TagName
+			if tags.fromTags {
+				tag = strings.TrimPrefix(r.Ref, "refs/tags/")
+			}
+			if v, ok := tags.version(tag); ok {
+				versions = append(versions, v)
+			}
+		}
+
+		// matching-refs is not paginated, so a single request lists every tag.
+		if tags.fromTags || len(releases) < 100 {
+			break
+		}
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no release of %s tagged %s<version>", repo, tags.prefix)
+	}
+	return versions, nil
+}
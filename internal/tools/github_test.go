@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type githubRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+func TestLatestGitHubRelease(t *testing.T) {
+	// The first page is full of unrelated releases so the plugin's are only found on the second.
+	var page1 []githubRelease
+	for i := 0; i < 100; i++ {
+		page1 = append(page1, githubRelease{TagName: fmt.Sprintf("v1.%d.0", 100-i)})
+	}
+	page2 := []githubRelease{
+		{TagName: "cmd/protoc-gen-go-grpc/v1.4.0", Draft: true},
+		{TagName: "cmd/protoc-gen-go-grpc/v1.3.1-rc.1"},
+		{TagName: "cmd/protoc-gen-go-grpc/v1.3.2", Prerelease: true},
+		{TagName: "cmd/protoc-gen-go-grpc/v1.3.0"},
+		{TagName: "cmd/protoc-gen-go-grpc/v1.10.0"},
+		{TagName: "cmd/protoc-gen-go-grpc/v1.2.0"},
+		{TagName: "cmd/other/v2.0.0"},
+		{TagName: "docs-1.0"},
+	}
+
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/grpc/grpc-go/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		switch r.URL.Query().Get("page") {
+		case "1":
+			_ = json.NewEncoder(w).Encode(page1)
+		case "2":
+			_ = json.NewEncoder(w).Encode(page2)
+		default:
+			_, _ = w.Write([]byte("[]"))
+		}
+	}))
+	defer srv.Close()
+
+	orig := githubAPIURL
+	githubAPIURL = srv.URL
+	t.Cleanup(func() {
+		githubAPIURL = orig
+	})
+
+	tests := []struct {
+		name     string
+		token    string
+		tags     *tagScheme
+		expected string
+		err      bool
+	}{
+		{
+			name:     "prefix",
+			tags:     &tagScheme{prefix: "cmd/protoc-gen-go-grpc/"},
+			expected: "v1.10.0",
+		},
+		{
+			name:     "pattern",
+			tags:     &tagScheme{prefix: "cmd/protoc-gen-go-grpc/", pattern: regexp.MustCompile(`^v1\.[0-3]\.`)},
+			expected: "v1.3.0",
+		},
+		{
+			name:     "token",
+			token:    "secret",
+			tags:     &tagScheme{prefix: "v", pattern: regexp.MustCompile(`^1\.`)},
+			expected: "1.100.0",
+		},
+		{
+			name: "no match",
+			tags: &tagScheme{prefix: "cmd/protoc-gen-missing/"},
+			err:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			m := &ToolManager{config: Config{GitHubToken: tt.token}}
+			v, err := m.latestGitHubRelease("github.com/grpc/grpc-go", tt.tags)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, v)
+			if tt.token != "" {
+				require.Equal(t, "Bearer "+tt.token, auth)
+			} else {
+				require.Empty(t, auth)
+			}
+		})
+	}
+}
+
+func TestGitHubTagVersionsSingleRequest(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// matching-refs ignores pagination and always returns every tag.
+		refs := make([]map[string]string, 0, 150)
+		for i := 0; i < 150; i++ {
+			refs = append(refs, map[string]string{"ref": fmt.Sprintf("refs/tags/1.%d.0", i)})
+		}
+		_ = json.NewEncoder(w).Encode(refs)
+	}))
+	defer srv.Close()
+	orig := githubAPIURL
+	githubAPIURL = srv.URL
+	t.Cleanup(func() {
+		githubAPIURL = orig
+	})
+
+	m := &ToolManager{}
+	versions, err := m.githubReleaseVersions("github.com/example/tools", &tagScheme{fromTags: true})
+	require.NoError(t, err)
+	require.Len(t, versions, 150)
+	require.Equal(t, 1, requests)
+}
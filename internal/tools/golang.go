@@ -0,0 +1,224 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// minSystemGoVersion is the oldest system Go used to build plugins when no version is requested. Go 1.21 is the
+// first to switch toolchains automatically when a plugin requires a newer one.
+const minSystemGoVersion = "go1.21"
+
+// goToolchain is the Go used to build plugins and download modules.
+type goToolchain struct {
+	// path is the path to the go executable.
+	path string
+	// version is the version reported by the executable, e.g. go1.21.3.
+	version string
+	// managed is set when the toolchain was downloaded by protog rather than found on the system.
+	managed bool
+}
+
+func (g *goToolchain) String() string {
+	source := "system"
+	if g.managed {
+		source = "managed"
+	}
+	return fmt.Sprintf("%s (%s, %s)", g.version, source, g.path)
+}
+
+// goModuleEnvVars configure how Go fetches modules and are passed through to plugin builds so plugins can be
+// installed from private module proxies and repositories.
+var goModuleEnvVars = []string{
+	"GOFLAGS",
+	"GOINSECURE",
+	"GONOPROXY",
+	"GONOSUMDB",
+	"GOPRIVATE",
+	"GOPROXY",
+	"GOSUMDB",
+	"GOVCS",
+	"NETRC",
+}
+
+// GoModuleEnv returns the variables of env that configure fetching Go modules. When NETRC is not set, it points
+// to the .netrc in HOME so credentials are still found without passing through the rest of the home directory.
+func GoModuleEnv(env map[string]string) map[string]string {
+	res := map[string]string{}
+	for _, k := range goModuleEnvVars {
+		if v := env[k]; v != "" {
+			res[k] = v
+		}
+	}
+	if _, ok := res["NETRC"]; !ok {
+		if home := env["HOME"]; home != "" {
+			res["NETRC"] = filepath.Join(home, ".netrc")
+		}
+	}
+	return res
+}
+
+// goVCSEnvVars are passed through from protog's environment to the go command so that, for modules fetched
+// directly from version control such as private ones, git can be found and use the user's configuration, ssh agent
+// and credential helpers.
+var goVCSEnvVars = []string{"PATH", "HOME", "USERPROFILE", "XDG_CONFIG_HOME", "SSH_AUTH_SOCK", "GIT_*"}
+
+// goEnv returns the environment for running the go command, adding the configured module environment and
+// toolchain to base, the variables needed by version control and any passed through variables.
+func (m *ToolManager) goEnv(base ...string) []string {
+	vars := map[string]string{}
+	for _, e := range environ() {
+		k, v, ok := strings.Cut(e, "=")
+		if !ok {
+			continue
+		}
+		for _, pattern := range goVCSEnvVars {
+			if matchesEnvPattern(k, pattern) {
+				vars[k] = v
+				break
+			}
+		}
+	}
+	for _, e := range base {
+		k, v, _ := strings.Cut(e, "=")
+		vars[k] = v
+	}
+	for k, v := range m.config.GoEnv {
+		vars[k] = v
+	}
+	if m.config.GoToolchain != "" {
+		vars["GOTOOLCHAIN"] = m.config.GoToolchain
+	}
+	return m.subprocessEnv(nil, vars)
+}
+
+// lookPathGo finds the system go, replaced in tests.
+var lookPathGo = func() (string, error) {
+	return exec.LookPath("go")
+}
+
+// resolveGo returns the Go toolchain to use, preferring a suitable system Go to downloading one. The choice
+// follows GOTOOLCHAIN semantics.
+//
+//   - local always uses the system Go.
+//   - A toolchain name such as go1.21.3, optionally with +auto or +path, requires that exact version.
+//   - Otherwise, a version set in Versions is required exactly, or to satisfy it if a constraint, and with none
+//     any system Go at least as new as minSystemGoVersion is used.
+//
+// When the system Go does not satisfy the requirement, the required version, or the latest, is downloaded.
+func (m *ToolManager) resolveGo(ctx context.Context) (*goToolchain, error) {
+	m.goMu.Lock()
+	defer m.goMu.Unlock()
+
+	if m.goToolchain != nil {
+		return m.goToolchain, nil
+	}
+
+	toolchain := m.config.GoToolchain
+	system, systemErr := systemGo()
+
+	var required string
+	switch {
+	case toolchain == "local":
+		if systemErr != nil {
+			return nil, fmt.Errorf("GOTOOLCHAIN=local but system go could not be used: %w", systemErr)
+		}
+		m.goToolchain = system
+		return system, nil
+	case strings.HasPrefix(toolchain, "go"):
+		required, _, _ = strings.Cut(toolchain, "+")
+	case isVersionConstraint(m.config.Versions["golang"]):
+		c, err := parseVersionConstraint(m.config.Versions["golang"])
+		if err != nil {
+			return nil, err
+		}
+		if systemErr == nil && c.matches(goSemver(system.version)) {
+			m.goToolchain = system
+			return system, nil
+		}
+		// Resolved to a concrete version when fetching.
+		required = m.config.Versions["golang"]
+	case m.config.Versions["golang"] != "":
+		required = m.config.Versions["golang"]
+		if !strings.HasPrefix(required, "go") {
+			required = "go" + strings.TrimPrefix(required, "v")
+		}
+	}
+
+	if systemErr == nil && !isVersionConstraint(required) {
+		if required != "" && system.version == required {
+			m.goToolchain = system
+			return system, nil
+		}
+		if required == "" && compareGoVersions(system.version, minSystemGoVersion) >= 0 {
+			m.goToolchain = system
+			return system, nil
+		}
+	}
+
+	inst, err := m.fetch(ctx, golangSpec, strings.TrimPrefix(required, "go"))
+	if err != nil {
+		return nil, err
+	}
+	managed := &goToolchain{path: inst.executables["go"], managed: true}
+	v, err := goVersion(managed.path)
+	if err != nil {
+		return nil, err
+	}
+	managed.version = v
+	m.goToolchain = managed
+	return managed, nil
+}
+
+func systemGo() (*goToolchain, error) {
+	path, err := lookPathGo()
+	if err != nil {
+		return nil, err
+	}
+	v, err := goVersion(path)
+	if err != nil {
+		return nil, err
+	}
+	return &goToolchain{path: path, version: v}, nil
+}
+
+// goVersion returns the version of the go executable at path itself, without switching toolchains.
+func goVersion(path string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command(path, "env", "GOVERSION")
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("determining version of %s: %w", path, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// compareGoVersions compares Go versions such as go1.21, go1.21.3 and go1.22rc1, returning -1, 0 or 1.
+func compareGoVersions(a, b string) int {
+	return semver.Compare(goSemver(a), goSemver(b))
+}
+
+// goSemver converts a Go version to semver, where go1.21 is the same as go1.21.0 and prereleases sort before it.
+func goSemver(v string) string {
+	v = strings.TrimPrefix(v, "go")
+	var pre string
+	for _, tag := range []string{"rc", "beta"} {
+		if i := strings.Index(v, tag); i >= 0 {
+			v, pre = v[:i], "-"+v[i:]
+			break
+		}
+	}
+	if strings.Count(v, ".") == 1 {
+		v += ".0"
+	}
+	return "v" + v + pre
+}
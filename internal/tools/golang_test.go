@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/module"
+	modzip "golang.org/x/mod/zip"
+)
+
+func TestCompareGoVersions(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{a: "go1.21", b: "go1.21.0", expected: 0},
+		{a: "go1.21.3", b: "go1.21", expected: 1},
+		{a: "go1.20.14", b: "go1.21", expected: -1},
+		{a: "go1.22rc1", b: "go1.22.0", expected: -1},
+		{a: "go1.22rc1", b: "go1.21.9", expected: 1},
+		{a: "go1.9", b: "go1.10", expected: -1},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			require.Equal(t, tt.expected, compareGoVersions(tt.a, tt.b))
+		})
+	}
+}
+
+func TestResolveGoSystem(t *testing.T) {
+	path, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not found on PATH")
+	}
+	ver, err := goVersion(path)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		toolchain string
+		version   string
+	}{
+		{name: "local", toolchain: "local"},
+		{name: "exact toolchain", toolchain: ver + "+auto"},
+		{name: "pinned version", version: ver},
+		{name: "constraint", version: ">=1.21"},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			m := &ToolManager{config: Config{GoToolchain: tt.toolchain, Versions: Versions{"golang": tt.version}}}
+			g, err := m.resolveGo(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, path, g.path)
+			require.Equal(t, ver, g.version)
+			require.False(t, g.managed)
+		})
+	}
+}
+
+func TestResolveGoLocalMissing(t *testing.T) {
+	orig := lookPathGo
+	lookPathGo = func() (string, error) {
+		return "", exec.ErrNotFound
+	}
+	t.Cleanup(func() {
+		lookPathGo = orig
+	})
+
+	m := &ToolManager{config: Config{GoToolchain: "local"}}
+	_, err := m.resolveGo(context.Background())
+	require.True(t, errors.Is(err, exec.ErrNotFound), "%v", err)
+}
+
+func TestFetchGoSpecModuleEnv(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found on PATH")
+	}
+
+	// A file-based GOPROXY serving a module that only exists there.
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/protoc-gen-private\n\ngo 1.18\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+
+	proxy := t.TempDir()
+	mod := module.Version{Path: "example.com/protoc-gen-private", Version: "v1.0.0"}
+	vDir := filepath.Join(proxy, "example.com", "protoc-gen-private", "@v")
+	require.NoError(t, os.MkdirAll(vDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(vDir, "list"), []byte("v1.0.0\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(vDir, "v1.0.0.info"), []byte(`{"Version":"v1.0.0"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(vDir, "v1.0.0.mod"), []byte("module example.com/protoc-gen-private\n\ngo 1.18\n"), 0644))
+	zf, err := os.Create(filepath.Join(vDir, "v1.0.0.zip"))
+	require.NoError(t, err)
+	require.NoError(t, modzip.CreateFromDir(zf, mod, src))
+	require.NoError(t, zf.Close())
+
+	proxyURL := "file://" + filepath.ToSlash(proxy)
+	if !strings.HasPrefix(proxyURL, "file:///") {
+		// Windows paths start with a drive letter.
+		proxyURL = "file:///" + filepath.ToSlash(proxy)
+	}
+
+	m := &ToolManager{
+		config: Config{
+			GoToolchain: "local",
+			GoEnv: GoModuleEnv(map[string]string{
+				"GOPROXY":   proxyURL,
+				"GONOSUMDB": "example.com",
+				"GOFLAGS":   "-trimpath",
+			}),
+		},
+		dir: t.TempDir(),
+	}
+	spec := goSpec{name: "protoc-gen-private", cmdPath: "example.com/protoc-gen-private"}
+	inst, err := m.fetchGoSpec(context.Background(), spec, "v1.0.0")
+	require.NoError(t, err)
+
+	exe := "protoc-gen-private"
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	require.Equal(t, filepath.Join(m.dir, "protoc-gen-private", "v1.0.0", "bin", exe), inst.executables["protoc-gen-private"])
+	_, err = os.Stat(inst.executables["protoc-gen-private"])
+	require.NoError(t, err)
+}
+
+func TestGoModuleEnv(t *testing.T) {
+	env := GoModuleEnv(map[string]string{
+		"GOPROXY": "https://proxy.example.com",
+		"HOME":    "/home/user",
+		"PATH":    "/usr/bin",
+	})
+	require.Equal(t, map[string]string{
+		"GOPROXY": "https://proxy.example.com",
+		"NETRC":   filepath.Join("/home/user", ".netrc"),
+	}, env)
+}
+
+func TestGoEnv(t *testing.T) {
+	environ = func() []string {
+		return []string{
+			"HOME=/home/user",
+			"PATH=/usr/bin",
+			"SSH_AUTH_SOCK=/tmp/agent.sock",
+			"GIT_SSH_COMMAND=ssh -i key",
+			"GOPROXY=https://ignored.example.com",
+			"SECRET=shh",
+		}
+	}
+	t.Cleanup(func() {
+		environ = os.Environ
+	})
+
+	m := &ToolManager{config: Config{
+		GoEnv:       map[string]string{"GOPRIVATE": "example.com"},
+		GoToolchain: "local",
+	}}
+	// Variables needed by git for modules fetched directly are always set, other ones only when passed through.
+	require.Equal(t, []string{
+		"PATH=" + mergePath([]string{"/usr/bin"}),
+		"CGO_ENABLED=0",
+		"GIT_SSH_COMMAND=ssh -i key",
+		"GOPRIVATE=example.com",
+		"GOTOOLCHAIN=local",
+		"HOME=/home/user",
+		"SSH_AUTH_SOCK=/tmp/agent.sock",
+	}, m.goEnv("CGO_ENABLED=0"))
+}
@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/curioswitch/protog/internal/proto"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// goModRuntime is the runtime library that code generated by a Go plugin depends on.
+type goModRuntime struct {
+	plugin string
+	module string
+	// compatible returns the versions of the plugin compatible with a version of the runtime. If nil, the plugin
+	// is released with the same version as the runtime.
+	compatible func(runtime string) string
+}
+
+var goModRuntimes = []goModRuntime{
+	{
+		plugin: "protoc-gen-go",
+		module: "google.golang.org/protobuf",
+	},
+	{
+		plugin: "protoc-gen-connect-go",
+		module: "connectrpc.com/connect",
+	},
+	{
+		plugin:     "protoc-gen-go-grpc",
+		module:     "google.golang.org/grpc",
+		compatible: compatibleGoGRPC,
+	},
+	{
+		plugin: "protoc-gen-grpc-gateway",
+		module: "github.com/grpc-ecosystem/grpc-gateway/v2",
+	},
+	{
+		plugin: "protoc-gen-validate",
+		module: "github.com/envoyproxy/protoc-gen-validate",
+	},
+	{
+		plugin: "protoc-gen-gogofast",
+		module: "github.com/gogo/protobuf",
+	},
+}
+
+// goGRPCPluginVersions are the protoc-gen-go-grpc releases and the oldest grpc-go able to compile their output,
+// newest first. protoc-gen-go-grpc is versioned independently of grpc-go.
+var goGRPCPluginVersions = []struct {
+	minGRPC string
+	plugin  string
+}{
+	{minGRPC: "v1.64.0", plugin: "~1.5"},
+	{minGRPC: "v1.62.0", plugin: "~1.4"},
+	{minGRPC: "v1.52.0", plugin: "~1.3"},
+	{minGRPC: "v1.32.0", plugin: "<1.3"},
+}
+
+func compatibleGoGRPC(runtime string) string {
+	for _, v := range goGRPCPluginVersions {
+		if semver.Compare(runtime, v.minGRPC) >= 0 {
+			return v.plugin
+		}
+	}
+	return ""
+}
+
+// applyGoModVersions sets the versions of Go plugins not set explicitly to match the runtime libraries required by
+// the Go module or workspace containing dir, and warns about explicit versions that do not match. goWork is the
+// value of GOWORK.
+func applyGoModVersions(versions Versions, dir string, goWork string) error {
+	requires, path, err := readGoRequirements(dir, goWork)
+	if err != nil || requires == nil {
+		return err
+	}
+
+	for _, r := range goModRuntimes {
+		runtime, ok := requires[r.module]
+		if !ok {
+			continue
+		}
+		derived := runtime
+		if r.compatible != nil {
+			derived = r.compatible(runtime)
+		} else if semver.Prerelease(runtime) != "" {
+			// Including pseudo-versions, which plugins are not released with.
+			derived = ""
+		}
+		if derived == "" {
+			fmt.Fprintf(os.Stderr, "warning: no release of %s matches %s %s in %s\n", r.plugin, r.module, runtime, path)
+			continue
+		}
+
+		explicit := versions[r.plugin]
+		if explicit == "" {
+			versions[r.plugin] = derived
+			continue
+		}
+		if !versionsAgree(explicit, derived) {
+			fmt.Fprintf(os.Stderr, "warning: %s version %s does not match %s %s in %s\n", r.plugin, explicit, r.module, runtime, path)
+		}
+	}
+	return nil
+}
+
+// versionsAgree returns whether an explicitly set version and one derived from go.mod, either of which may be a
+// constraint, can be satisfied by the same version.
+func versionsAgree(explicit, derived string) bool {
+	constraint, exact := explicit, derived
+	if !isVersionConstraint(constraint) {
+		constraint, exact = derived, explicit
+	}
+	if isVersionConstraint(exact) {
+		// Overlapping constraints are not checked, so they are assumed to agree.
+		return true
+	}
+	sv, ok := normalizeVersion(exact)
+	if !ok {
+		return false
+	}
+	if !isVersionConstraint(constraint) {
+		other, ok := normalizeVersion(constraint)
+		return ok && semver.Compare(sv, other) == 0
+	}
+	c, err := parseVersionConstraint(constraint)
+	return err == nil && c.matches(sv)
+}
+
+// readGoRequirements returns the required module versions of the Go workspace or module containing dir, with
+// replacements applied, and the path of the file they were read from. If there is neither, it returns nil. Within
+// a workspace, the highest version required by any of its modules is used as with minimal version selection.
+// goWork is the value of GOWORK, which may disable workspaces or select a specific one.
+func readGoRequirements(dir string, goWork string) (map[string]string, string, error) {
+	if goWork != "off" {
+		workPath := goWork
+		if workPath == "" {
+			var err error
+			if workPath, err = proto.FindUp(dir, "go.work"); err != nil {
+				return nil, "", err
+			}
+		}
+		if workPath != "" {
+			b, err := os.ReadFile(workPath)
+			if err != nil {
+				return nil, "", err
+			}
+			work, err := modfile.ParseWork(workPath, b, nil)
+			if err != nil {
+				return nil, "", err
+			}
+			requires := map[string]string{}
+			for _, use := range work.Use {
+				modPath := filepath.Join(filepath.Dir(workPath), filepath.FromSlash(use.Path), "go.mod")
+				if err := addGoModRequirements(requires, modPath); err != nil {
+					return nil, "", err
+				}
+			}
+			applyReplaces(requires, work.Replace)
+			return requires, workPath, nil
+		}
+	}
+
+	modPath, err := proto.FindUp(dir, "go.mod")
+	if err != nil || modPath == "" {
+		return nil, "", err
+	}
+	requires := map[string]string{}
+	if err := addGoModRequirements(requires, modPath); err != nil {
+		return nil, "", err
+	}
+	return requires, modPath, nil
+}
+
+func addGoModRequirements(requires map[string]string, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f, err := modfile.Parse(path, b, nil)
+	if err != nil {
+		return err
+	}
+	mod := map[string]string{}
+	for _, r := range f.Require {
+		mod[r.Mod.Path] = r.Mod.Version
+	}
+	applyReplaces(mod, f.Replace)
+	for p, v := range mod {
+		if cur, ok := requires[p]; !ok || semver.Compare(v, cur) > 0 {
+			requires[p] = v
+		}
+	}
+	return nil
+}
+
+// applyReplaces applies replacements by another version of the same module. Replacements by a local directory or
+// a fork are left as the required version, the best indication of what the replacement is based on.
+func applyReplaces(requires map[string]string, replaces []*modfile.Replace) {
+	for _, r := range replaces {
+		if _, ok := requires[r.Old.Path]; !ok || r.New.Path != r.Old.Path || r.New.Version == "" {
+			continue
+		}
+		if r.Old.Version == "" || r.Old.Version == requires[r.Old.Path] {
+			requires[r.Old.Path] = r.New.Version
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyGoModVersions(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(path, content string) {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	writeFile("api/go.mod", `module example.com/api
+
+go 1.21
+
+require (
+	connectrpc.com/connect v1.11.0
+	google.golang.org/grpc v1.58.3
+	google.golang.org/protobuf v1.31.0
+	github.com/envoyproxy/protoc-gen-validate v1.0.3-0.20231010000000-abcdefabcdef
+)
+
+replace connectrpc.com/connect => connectrpc.com/connect v1.11.1
+`)
+	writeFile("server/go.mod", `module example.com/server
+
+go 1.21
+
+require (
+	google.golang.org/protobuf v1.32.0
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.18.1
+)
+`)
+
+	tests := []struct {
+		name     string
+		goWork   string
+		dir      string
+		versions Versions
+		expected Versions
+	}{
+		{
+			name:   "module",
+			dir:    "api",
+			goWork: "off",
+			expected: Versions{
+				"protoc-gen-connect-go": "v1.11.1",
+				"protoc-gen-go-grpc":    "~1.3",
+				"protoc-gen-go":         "v1.31.0",
+			},
+		},
+		{
+			name:   "explicit versions kept",
+			dir:    "api",
+			goWork: "off",
+			versions: Versions{
+				"protoc-gen-go":      "v1.30.0",
+				"protoc-gen-go-grpc": "1.2.0",
+			},
+			expected: Versions{
+				"protoc-gen-connect-go": "v1.11.1",
+				"protoc-gen-go-grpc":    "1.2.0",
+				"protoc-gen-go":         "v1.30.0",
+			},
+		},
+		{
+			name:   "workspace",
+			dir:    "server",
+			goWork: filepath.Join(dir, "go.work"),
+			expected: Versions{
+				"protoc-gen-connect-go":   "v1.11.1",
+				"protoc-gen-go-grpc":      "~1.3",
+				"protoc-gen-go":           "v1.32.0",
+				"protoc-gen-grpc-gateway": "v2.18.1",
+			},
+		},
+		{
+			name:   "workspace disabled",
+			dir:    "server",
+			goWork: "off",
+			expected: Versions{
+				"protoc-gen-go":           "v1.32.0",
+				"protoc-gen-grpc-gateway": "v2.18.1",
+			},
+		},
+		{
+			name:     "no module",
+			goWork:   "off",
+			expected: Versions{},
+		},
+	}
+
+	writeFile("go.work", `go 1.21
+
+use (
+	./api
+	./server
+)
+`)
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			versions := Versions{}
+			for k, v := range tt.versions {
+				versions[k] = v
+			}
+			d := t.TempDir()
+			if tt.dir != "" {
+				d = filepath.Join(dir, tt.dir)
+			}
+			require.NoError(t, applyGoModVersions(versions, d, tt.goWork))
+			require.Equal(t, tt.expected, versions)
+		})
+	}
+}
+
+func TestVersionsAgree(t *testing.T) {
+	require.True(t, versionsAgree("1.31.0", "v1.31.0"))
+	require.False(t, versionsAgree("v1.30.0", "v1.31.0"))
+	require.True(t, versionsAgree("v1.3.2", "~1.3"))
+	require.False(t, versionsAgree("v1.2.0", "~1.3"))
+	require.True(t, versionsAgree("^1.31", "v1.32.0"))
+	require.True(t, versionsAgree("^1.2", "~1.3"))
+}
@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+const defaultGoProxy = "https://proxy.golang.org,direct"
+
+// errNoModuleRelease is returned when the module proxy does not know a release of a module, for example because
+// it is private or only has non-semver tags, in which case the version is resolved some other way.
+var errNoModuleRelease = errors.New("no release found on module proxy")
+
+// errModuleNotFound is returned by a proxy that does not know a module path, which may then be tried on the next
+// proxy or as a parent path.
+var errModuleNotFound = errors.New("module not found")
+
+var goProxyClient = &http.Client{Timeout: 30 * time.Second}
+
+// latestGoModuleVersion returns the latest release version of the module providing the package cmdPath using the
+// GOPROXY protocol. Prereleases and pseudo-versions are not considered releases.
+func (m *ToolManager) latestGoModuleVersion(cmdPath string) (string, error) {
+	versions, err := m.goModuleVersions(cmdPath)
+	if err != nil {
+		return "", err
+	}
+	latest := versions[0]
+	for _, v := range versions[1:] {
+		if semver.Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest, nil
+}
+
+// goModuleVersions returns the release versions of the module providing the package cmdPath using the GOPROXY
+// protocol. As with the go command, the longest module path providing the package wins, so commands that are their
+// own module in a multi-module repository are resolved correctly.
+func (m *ToolManager) goModuleVersions(cmdPath string) ([]string, error) {
+	proxies, noProxy := m.goProxies()
+	for modPath := cmdPath; modPath != "." && strings.Contains(modPath, "/"); modPath = path.Dir(modPath) {
+		if module.MatchPrefixPatterns(noProxy, modPath) {
+			return nil, errNoModuleRelease
+		}
+		versions, err := versionsFromProxies(proxies, modPath)
+		if errors.Is(err, errModuleNotFound) {
+			continue
+		}
+		return versions, err
+	}
+	return nil, errNoModuleRelease
+}
+
+// goProxies returns the configured GOPROXY list and the module path patterns not to look up on it.
+func (m *ToolManager) goProxies() (string, string) {
+	proxies := m.config.GoEnv["GOPROXY"]
+	if proxies == "" {
+		proxies = defaultGoProxy
+	}
+	noProxy := m.config.GoEnv["GONOPROXY"]
+	if noProxy == "" {
+		noProxy = m.config.GoEnv["GOPRIVATE"]
+	}
+	return proxies, noProxy
+}
+
+// versionsFromProxies queries each proxy of a GOPROXY list in turn. As with the go command, a comma only falls
+// back to the next proxy when a module is not found and a pipe falls back on any error. Version control is not
+// queried directly, so direct is treated as not finding the module.
+func versionsFromProxies(proxies string, modPath string) ([]string, error) {
+	lastErr := errModuleNotFound
+	for proxies != "" {
+		var proxy string
+		fallbackOnError := false
+		if i := strings.IndexAny(proxies, ",|"); i >= 0 {
+			proxy, fallbackOnError, proxies = proxies[:i], proxies[i] == '|', proxies[i+1:]
+		} else {
+			proxy, proxies = proxies, ""
+		}
+
+		switch proxy {
+		case "", "direct":
+			continue
+		case "off":
+			return nil, fmt.Errorf("module lookup disabled by GOPROXY=off")
+		}
+
+		versions, err := versionsFromProxy(proxy, modPath)
+		if err == nil || (!fallbackOnError && !errors.Is(err, errModuleNotFound)) {
+			return versions, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func versionsFromProxy(proxy string, modPath string) ([]string, error) {
+	escaped, err := module.EscapePath(modPath)
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(proxy, "/") + "/" + escaped + "/@v/"
+
+	list, err := readGoProxy(base + "list")
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, line := range strings.Split(string(list), "\n") {
+		if v := strings.TrimSpace(line); isGoRelease(v) {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) > 0 {
+		return versions, nil
+	}
+
+	// The list only contains tagged versions, and @latest also considers untagged ones.
+	b, err := readGoProxy(strings.TrimSuffix(base, "@v/") + "@latest")
+	if err != nil {
+		if errors.Is(err, errModuleNotFound) {
+			// The module exists but has no versions at all.
+			return nil, errNoModuleRelease
+		}
+		return nil, err
+	}
+	var info struct {
+		Version string
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, fmt.Errorf("parsing %s@latest from %s: %w", modPath, proxy, err)
+	}
+	if !isGoRelease(info.Version) {
+		return nil, errNoModuleRelease
+	}
+	return []string{info.Version}, nil
+}
+
+func isGoRelease(v string) bool {
+	return semver.IsValid(v) && semver.Prerelease(v) == "" && !module.IsPseudoVersion(v)
+}
+
+// readGoProxy reads a file from a module proxy, which may be an HTTP server or a file:// directory.
+func readGoProxy(u string) ([]byte, error) {
+	if strings.HasPrefix(u, "file://") {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		p := parsed.Path
+		// file:///C:/proxy on Windows.
+		if len(p) > 2 && p[0] == '/' && p[2] == ':' {
+			p = p[1:]
+		}
+		b, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			return nil, errModuleNotFound
+		}
+		return b, err
+	}
+
+	resp, err := goProxyClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound, http.StatusGone:
+		return nil, errModuleNotFound
+	default:
+		return nil, fmt.Errorf("fetching %s: %s", u, resp.Status)
+	}
+}
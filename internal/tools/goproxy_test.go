@@ -0,0 +1,190 @@
+package tools
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestGoModuleVersion(t *testing.T) {
+	proxy := t.TempDir()
+	writeProxyFile := func(modPath, name, content string) {
+		dir := filepath.Join(proxy, filepath.FromSlash(modPath), "@v")
+		if name == "@latest" {
+			dir = filepath.Dir(dir)
+		}
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	// A repository with a command in its own module, tagged separately from the root module.
+	writeProxyFile("example.com/repo", "list", "v1.50.0\nv1.51.0\n")
+	writeProxyFile("example.com/repo/cmd/protoc-gen-foo", "list", "v1.2.0\nv1.10.0\nv1.11.0-rc.1\n")
+	// A command that is a package within its module.
+	writeProxyFile("example.com/tools", "list", "v0.9.0\nv0.10.0\nv0.11.0-pre\n")
+	// A module without tags.
+	writeProxyFile("example.com/untagged", "list", "")
+	writeProxyFile("example.com/untagged", "@latest", `{"Version":"v0.0.0-20230102030405-abcdefabcdef"}`)
+	// Upper case is escaped in module paths.
+	writeProxyFile("example.com/!upper", "list", "v2.0.0+incompatible\nv1.0.0\n")
+
+	fileProxy := "file://" + filepath.ToSlash(proxy)
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		cmdPath  string
+		expected string
+		err      error
+	}{
+		{
+			name:     "own module",
+			cmdPath:  "example.com/repo/cmd/protoc-gen-foo",
+			expected: "v1.10.0",
+		},
+		{
+			name:     "package in module",
+			cmdPath:  "example.com/tools/cmd/protoc-gen-bar",
+			expected: "v0.10.0",
+		},
+		{
+			name:     "escaped",
+			cmdPath:  "example.com/Upper/cmd/protoc-gen-baz",
+			expected: "v2.0.0+incompatible",
+		},
+		{
+			name:    "untagged",
+			cmdPath: "example.com/untagged",
+			err:     errNoModuleRelease,
+		},
+		{
+			name:    "unknown",
+			cmdPath: "example.com/unknown/cmd/protoc-gen-qux",
+			err:     errNoModuleRelease,
+		},
+		{
+			name:    "private",
+			env:     map[string]string{"GOPRIVATE": "example.com/repo"},
+			cmdPath: "example.com/repo/cmd/protoc-gen-foo",
+			err:     errNoModuleRelease,
+		},
+		{
+			name:    "direct",
+			env:     map[string]string{"GOPROXY": "direct"},
+			cmdPath: "example.com/repo/cmd/protoc-gen-foo",
+			err:     errNoModuleRelease,
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"GOPROXY": fileProxy}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			m := &ToolManager{config: Config{GoEnv: env}}
+			v, err := m.latestGoModuleVersion(tt.cmdPath)
+			if tt.err != nil {
+				require.True(t, errors.Is(err, tt.err), "%v", err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestLatestGoModuleVersionFallback(t *testing.T) {
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/example.com/plugin/@v/list" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("v1.0.0\nv1.1.0\n"))
+	}))
+	defer working.Close()
+
+	tests := []struct {
+		name     string
+		proxy    string
+		expected string
+		err      bool
+	}{
+		{name: "comma after not found", proxy: notFound.URL + "," + working.URL, expected: "v1.1.0"},
+		{name: "comma after error", proxy: broken.URL + "," + working.URL, err: true},
+		{name: "pipe after error", proxy: broken.URL + "|" + working.URL, expected: "v1.1.0"},
+		{name: "off", proxy: "off", err: true},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			m := &ToolManager{config: Config{GoEnv: map[string]string{"GOPROXY": tt.proxy}}}
+			v, err := m.latestGoModuleVersion("example.com/plugin")
+			if tt.err {
+				require.Error(t, err)
+				require.False(t, errors.Is(err, errNoModuleRelease))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestGoSpecLatestFromTags(t *testing.T) {
+	proxy := t.TempDir()
+	dir := filepath.Join(proxy, "example.com", "tools")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "@v"), 0755))
+	// Tags without a v prefix are not module versions, so the proxy only has a pseudo-version.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "@v", "list"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "@latest"), []byte(`{"Version":"v0.0.0-20231012123456-abcdefabcdef"}`), 0644))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/example/tools/git/matching-refs/tags/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`[
+  {"ref": "refs/tags/1.9.5"},
+  {"ref": "refs/tags/1.20.0"},
+  {"ref": "refs/tags/1.20.1-beta.0"},
+  {"ref": "refs/tags/1.19.3"},
+  {"ref": "refs/tags/latest"}
+]`))
+	}))
+	defer srv.Close()
+	orig := githubAPIURL
+	githubAPIURL = srv.URL
+	t.Cleanup(func() {
+		githubAPIURL = orig
+	})
+
+	m := &ToolManager{config: Config{GoEnv: map[string]string{"GOPROXY": "file://" + filepath.ToSlash(proxy)}}}
+	src := m.goSpecSource(goSpec{
+		name:       "protoc-gen-tools",
+		repo:       "github.com/example/tools",
+		cmdPath:    "example.com/tools/cmd/protoc-gen-tools",
+		versionNoV: true,
+		tags:       &tagScheme{fromTags: true},
+	})
+
+	v, err := src.latest()
+	require.NoError(t, err)
+	require.Equal(t, "1.20.0", v)
+
+	versions, err := src.versions()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"1.9.5", "1.20.0", "1.19.3"}, versions)
+}
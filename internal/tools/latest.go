@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultLatestTTL is how long a resolved latest version is reused before resolving it again.
+const DefaultLatestTTL = 24 * time.Hour
+
+// latestVersion is a resolved latest version of a tool.
+type latestVersion struct {
+	Version  string    `json:"version"`
+	Resolved time.Time `json:"resolved"`
+}
+
+func (m *ToolManager) latestVersionsPath() string {
+	return filepath.Join(m.dir, "latest-versions.json")
+}
+
+// loadLatestVersions reads the resolved latest versions, which are empty if none have been saved or they are
+// unreadable since they can always be resolved again.
+func (m *ToolManager) loadLatestVersions() map[string]latestVersion {
+	if m.latestVersions != nil {
+		return m.latestVersions
+	}
+	m.latestVersions = map[string]latestVersion{}
+	if b, err := os.ReadFile(m.latestVersionsPath()); err == nil {
+		_ = json.Unmarshal(b, &m.latestVersions)
+	}
+	return m.latestVersions
+}
+
+func (m *ToolManager) saveLatestVersions() error {
+	b, err := json.MarshalIndent(m.latestVersions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+	// Write atomically since concurrent invocations may read the file.
+	tmp, err := os.CreateTemp(m.dir, "latest-versions-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), m.latestVersionsPath())
+}
+
+type latestCall struct {
+	done    chan struct{}
+	version string
+	err     error
+}
+
+// cachedLatest returns the latest version of a tool, reusing a previously resolved one for the configured TTL
+// unless refreshing, which resolves each tool only once per ToolManager. When resolving fails, for example when
+// offline, a previously resolved version is used regardless of its age. Concurrent calls for the same key wait for
+// a single resolution while other keys are resolved in parallel.
+func (m *ToolManager) cachedLatest(key string, resolve func() (string, error)) (string, error) {
+	ttl := m.config.LatestTTL
+	if ttl == 0 {
+		ttl = DefaultLatestTTL
+	}
+
+	m.latestMu.Lock()
+	versions := m.loadLatestVersions()
+	cached, ok := versions[key]
+	refresh := m.config.Refresh && !m.latestRefreshed[key]
+	if ok && !refresh && time.Since(cached.Resolved) < ttl {
+		m.latestMu.Unlock()
+		return cached.Version, nil
+	}
+	if c, ok := m.latestCalls[key]; ok {
+		m.latestMu.Unlock()
+		<-c.done
+		return c.version, c.err
+	}
+	if m.latestCalls == nil {
+		m.latestCalls = map[string]*latestCall{}
+	}
+	c := &latestCall{done: make(chan struct{})}
+	m.latestCalls[key] = c
+	m.latestMu.Unlock()
+
+	v, err := resolve()
+
+	m.latestMu.Lock()
+	defer m.latestMu.Unlock()
+	defer close(c.done)
+	delete(m.latestCalls, key)
+	if err != nil {
+		if ok {
+			fmt.Fprintf(os.Stderr, "could not resolve latest version of %s, using %s resolved at %s: %v\n",
+				key, cached.Version, cached.Resolved.Format(time.RFC3339), err)
+			c.version = cached.Version
+			return c.version, nil
+		}
+		c.err = err
+		return "", err
+	}
+
+	if m.latestRefreshed == nil {
+		m.latestRefreshed = map[string]bool{}
+	}
+	m.latestRefreshed[key] = true
+	versions[key] = latestVersion{Version: v, Resolved: time.Now()}
+	if err := m.saveLatestVersions(); err != nil {
+		// Only a cache, so it is fine to resolve again next time.
+		fmt.Fprintf(os.Stderr, "could not save latest version of %s: %v\n", key, err)
+	}
+	c.version = v
+	return v, nil
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedLatest(t *testing.T) {
+	dir := t.TempDir()
+
+	calls := 0
+	resolved := "v1.0.0"
+	var resolveErr error
+	resolve := func() (string, error) {
+		calls++
+		return resolved, resolveErr
+	}
+
+	m := &ToolManager{dir: dir}
+	v, err := m.cachedLatest("protoc", resolve)
+	require.NoError(t, err)
+	require.Equal(t, "v1.0.0", v)
+	require.Equal(t, 1, calls)
+
+	// Reused by later invocations within the TTL.
+	resolved = "v1.1.0"
+	m = &ToolManager{dir: dir}
+	v, err = m.cachedLatest("protoc", resolve)
+	require.NoError(t, err)
+	require.Equal(t, "v1.0.0", v)
+	require.Equal(t, 1, calls)
+
+	// Refreshing resolves again.
+	m = &ToolManager{dir: dir, config: Config{Refresh: true}}
+	v, err = m.cachedLatest("protoc", resolve)
+	require.NoError(t, err)
+	require.Equal(t, "v1.1.0", v)
+	require.Equal(t, 2, calls)
+
+	// As does an expired TTL.
+	resolved = "v1.2.0"
+	m = &ToolManager{dir: dir, config: Config{LatestTTL: time.Nanosecond}}
+	v, err = m.cachedLatest("protoc", resolve)
+	require.NoError(t, err)
+	require.Equal(t, "v1.2.0", v)
+	require.Equal(t, 3, calls)
+
+	// Failing to resolve, e.g. when offline, falls back to the cached version.
+	resolveErr = errors.New("offline")
+	m = &ToolManager{dir: dir, config: Config{Refresh: true}}
+	v, err = m.cachedLatest("protoc", resolve)
+	require.NoError(t, err)
+	require.Equal(t, "v1.2.0", v)
+	require.Equal(t, 4, calls)
+
+	// Unless there is none.
+	_, err = m.cachedLatest("protoc-gen-go", resolve)
+	require.Error(t, err)
+}
+
+func TestCachedLatestRefreshOnce(t *testing.T) {
+	calls := 0
+	resolve := func() (string, error) {
+		calls++
+		return "v1.0.0", nil
+	}
+
+	// A long-lived ToolManager only refreshes each tool once.
+	m := &ToolManager{dir: t.TempDir(), config: Config{Refresh: true}}
+	for i := 0; i < 3; i++ {
+		v, err := m.cachedLatest("protoc", resolve)
+		require.NoError(t, err)
+		require.Equal(t, "v1.0.0", v)
+	}
+	require.Equal(t, 1, calls)
+
+	_, err := m.cachedLatest("protoc-gen-go", resolve)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestCachedLatestConcurrent(t *testing.T) {
+	m := &ToolManager{dir: t.TempDir()}
+
+	// A slow resolution does not block resolving other tools.
+	release := make(chan struct{})
+	var slowCalls int
+	var slowMu sync.Mutex
+	slow := func() (string, error) {
+		slowMu.Lock()
+		slowCalls++
+		slowMu.Unlock()
+		<-release
+		return "v1.0.0", nil
+	}
+
+	var wg sync.WaitGroup
+	versions := make([]string, 5)
+	errs := make([]error, len(versions))
+	for i := range versions {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			versions[i], errs[i] = m.cachedLatest("protoc", slow)
+		}()
+	}
+
+	v, err := m.cachedLatest("protoc-gen-go", func() (string, error) {
+		return "v2.0.0", nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "v2.0.0", v)
+
+	close(release)
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	// Concurrent calls for the same tool share one resolution.
+	require.Equal(t, 1, slowCalls)
+	require.Equal(t, []string{"v1.0.0", "v1.0.0", "v1.0.0", "v1.0.0", "v1.0.0"}, versions)
+}
@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultNpmRegistry = "https://registry.npmjs.org"
+
+var npmRegistryClient = &http.Client{Timeout: 30 * time.Second}
+
+// npmEnv returns the environment for running npm with NodeJS in path, using the configured registry and any
+// passed through variables.
+func (m *ToolManager) npmEnv(path []string) []string {
+	vars := map[string]string{}
+	if m.config.NpmRegistry != "" {
+		vars["npm_config_registry"] = m.config.NpmRegistry
+	}
+	return m.subprocessEnv(path, vars)
+}
+
+// isNpmDistTag returns whether ver names a dist-tag, such as latest or next, rather than a version.
+func isNpmDistTag(ver string) bool {
+	v := strings.TrimPrefix(ver, "v")
+	return v == "" || v[0] < '0' || v[0] > '9'
+}
+
+// npmPackument is the abbreviated metadata of a package in the npm registry.
+type npmPackument struct {
+	DistTags map[string]string          `json:"dist-tags"`
+	Versions map[string]json.RawMessage `json:"versions"`
+}
+
+// npmRegistry returns the configured registry, or the public one if not configured.
+func (m *ToolManager) npmRegistry() string {
+	if m.config.NpmRegistry == "" {
+		return defaultNpmRegistry
+	}
+	return m.config.NpmRegistry
+}
+
+// fetchNpmPackument fetches the metadata of a package from the configured registry.
+func (m *ToolManager) fetchNpmPackument(pkg string) (*npmPackument, error) {
+	// Scoped packages keep their @ but escape the separator.
+	u := strings.TrimSuffix(m.npmRegistry(), "/") + "/" + strings.Replace(pkg, "/", "%2f", 1)
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The abbreviated metadata is much smaller than the full document and contains what is needed.
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json")
+	resp, err := npmRegistryClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s from npm registry: %w", pkg, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s from npm registry: %s", pkg, resp.Status)
+	}
+
+	var packument npmPackument
+	if err := json.NewDecoder(resp.Body).Decode(&packument); err != nil {
+		return nil, fmt.Errorf("parsing %s from npm registry: %w", pkg, err)
+	}
+	return &packument, nil
+}
+
+// resolveNpmDistTag returns the version a dist-tag of an npm package points to in the configured registry.
+func (m *ToolManager) resolveNpmDistTag(pkg string, tag string) (string, error) {
+	packument, err := m.fetchNpmPackument(pkg)
+	if err != nil {
+		return "", err
+	}
+	v, ok := packument.DistTags[tag]
+	if !ok {
+		return "", fmt.Errorf("npm package %s has no dist-tag %s", pkg, tag)
+	}
+	return v, nil
+}
+
+// npmVersions returns the published versions of an npm package in the configured registry.
+func (m *ToolManager) npmVersions(pkg string) ([]string, error) {
+	packument, err := m.fetchNpmPackument(pkg)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(packument.Versions))
+	for v := range packument.Versions {
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
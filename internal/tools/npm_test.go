@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveNpmDistTag(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/@bufbuild%2fprotoc-gen-es" {
+			http.NotFound(w, r)
+			return
+		}
+		// The handler runs on another goroutine, where require cannot stop the test.
+		assert.Equal(t, "application/vnd.npm.install-v1+json", r.Header.Get("Accept"))
+		_, _ = w.Write([]byte(`{"name":"@bufbuild/protoc-gen-es","dist-tags":{"latest":"1.3.1","next":"2.0.0-alpha.1","legacy":"0.5.0"}}`))
+	}))
+	defer registry.Close()
+
+	tests := []struct {
+		pkg      string
+		tag      string
+		expected string
+		err      bool
+	}{
+		{pkg: "@bufbuild/protoc-gen-es", tag: "latest", expected: "1.3.1"},
+		{pkg: "@bufbuild/protoc-gen-es", tag: "next", expected: "2.0.0-alpha.1"},
+		{pkg: "@bufbuild/protoc-gen-es", tag: "legacy", expected: "0.5.0"},
+		{pkg: "@bufbuild/protoc-gen-es", tag: "beta", err: true},
+		{pkg: "protoc-gen-ts", tag: "latest", err: true},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.pkg+"@"+tt.tag, func(t *testing.T) {
+			m := &ToolManager{config: Config{NpmRegistry: registry.URL + "/"}}
+			v, err := m.resolveNpmDistTag(tt.pkg, tt.tag)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestIsNpmDistTag(t *testing.T) {
+	require.True(t, isNpmDistTag(""))
+	require.True(t, isNpmDistTag("latest"))
+	require.True(t, isNpmDistTag("next"))
+	require.False(t, isNpmDistTag("1.3.1"))
+	require.False(t, isNpmDistTag("v1.3.1"))
+}
+
+func TestRenamedNodeSpecDistTags(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/@bufbuild%2fprotoc-gen-connect-es":
+			_, _ = w.Write([]byte(`{"name":"@bufbuild/protoc-gen-connect-es","dist-tags":{"latest":"0.13.0","next":"0.14.0-alpha.1"}}`))
+		case "/@connectrpc%2fprotoc-gen-connect-es":
+			_, _ = w.Write([]byte(`{"name":"@connectrpc/protoc-gen-connect-es","dist-tags":{"latest":"1.4.0","next":"2.0.0-alpha.1"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer registry.Close()
+
+	m := &ToolManager{dir: t.TempDir(), config: Config{NpmRegistry: registry.URL + "/"}}
+
+	v, err := m.LatestVersion("protoc-gen-connect-es", "")
+	require.NoError(t, err)
+	require.Equal(t, "1.4.0", v)
+
+	v, err = m.resolveNodeDistTag(protocGenConnectESSpec, "next")
+	require.NoError(t, err)
+	require.Equal(t, "2.0.0-alpha.1", v)
+
+	// Packages that were not renamed use their own dist-tags.
+	v, err = m.resolveNodeDistTag(nodeSpec{name: "@bufbuild/protoc-gen-connect-es"}, "next")
+	require.NoError(t, err)
+	require.Equal(t, "0.14.0-alpha.1", v)
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// VersionStatus compares the configured version of a tool with the versions available.
+type VersionStatus struct {
+	Tool    string
+	Current string
+	// Wanted is the highest version satisfying Current if it is a constraint, or otherwise within the same major
+	// version as Current. It is empty if Current is not a semantic version.
+	Wanted string
+	Latest string
+	// MajorBump is whether Latest is outside the major version of Wanted.
+	MajorBump bool
+}
+
+// CheckVersion compares current, the configured version of tool, with its latest version.
+func (m *ToolManager) CheckVersion(tool string, current string) (VersionStatus, error) {
+	status := VersionStatus{Tool: tool, Current: current}
+
+	latest, err := m.LatestVersion(tool, "")
+	if err != nil {
+		return status, err
+	}
+	status.Latest = latest
+
+	bounds := current
+	if !isVersionConstraint(bounds) {
+		sv, ok := normalizeVersion(current)
+		if !ok {
+			return status, nil
+		}
+		bounds = "^" + versionCore(sv)
+	}
+	wanted, err := m.LatestVersion(tool, bounds)
+	if err != nil {
+		return status, err
+	}
+	status.Wanted = wanted
+
+	wsv, _ := normalizeVersion(wanted)
+	major, err := parseVersionConstraint("^" + versionCore(wsv))
+	if err != nil {
+		return status, err
+	}
+	if sv, ok := normalizeVersion(latest); ok {
+		status.MajorBump = !major.matches(sv)
+	}
+	return status, nil
+}
+
+// UpgradeVersion returns the version to upgrade current, the configured version of tool, to. within limits the
+// upgrade to versions with the same major version of current if "major", the same minor version if "minor", to a
+// constraint, or to none if empty. The result has the same prefix, e.g. v, as current.
+func (m *ToolManager) UpgradeVersion(tool string, current string, within string) (string, error) {
+	if isVersionConstraint(current) {
+		return "", fmt.Errorf("%s version %s is a constraint, resolved when run", tool, current)
+	}
+	sv, ok := normalizeVersion(current)
+	if !ok {
+		return "", fmt.Errorf("%s version %s is not a semantic version", tool, current)
+	}
+	bare := versionCore(sv)
+
+	var constraint string
+	switch within {
+	case "":
+	case "major":
+		constraint = "^" + bare
+	case "minor":
+		constraint = "~" + bare
+	default:
+		if _, err := parseVersionConstraint(within); err != nil {
+			return "", err
+		}
+		constraint = within
+	}
+
+	upgraded, err := m.LatestVersion(tool, constraint)
+	if err != nil {
+		return "", err
+	}
+	usv, ok := normalizeVersion(upgraded)
+	if !ok {
+		return "", fmt.Errorf("latest %s version %s is not a semantic version", tool, upgraded)
+	}
+	// Never downgrade, e.g. when pinned to a prerelease.
+	if semver.Compare(usv, sv) <= 0 {
+		return current, nil
+	}
+	upgraded = strings.TrimPrefix(usv, "v")
+	switch {
+	case strings.HasPrefix(current, "go"):
+		return "go" + upgraded, nil
+	case strings.HasPrefix(current, "v"):
+		return "v" + upgraded, nil
+	default:
+		return upgraded, nil
+	}
+}
+
+// versionCore returns the major, minor and patch version of a semantic version, without the v prefix.
+func versionCore(sv string) string {
+	return strings.TrimPrefix(strings.TrimSuffix(semver.Canonical(sv), semver.Prerelease(sv)), "v")
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAndUpgradeVersion(t *testing.T) {
+	toolSpecs["protoc-gen-fake"] = spec{
+		name: "protoc-gen-fake",
+		latestVer: func() (string, error) {
+			return "v2.1.0", nil
+		},
+		versions: func() ([]string, error) {
+			return []string{"v1.2.0", "v1.2.3", "v1.3.0", "v2.0.0", "v2.1.0", "v2.2.0-rc.1"}, nil
+		},
+	}
+	t.Cleanup(func() {
+		delete(toolSpecs, "protoc-gen-fake")
+	})
+
+	m := &ToolManager{dir: t.TempDir()}
+
+	s, err := m.CheckVersion("protoc-gen-fake", "1.2.0")
+	require.NoError(t, err)
+	require.Equal(t, VersionStatus{Tool: "protoc-gen-fake", Current: "1.2.0", Wanted: "v1.3.0", Latest: "v2.1.0", MajorBump: true}, s)
+
+	s, err = m.CheckVersion("protoc-gen-fake", "~2.0")
+	require.NoError(t, err)
+	require.Equal(t, VersionStatus{Tool: "protoc-gen-fake", Current: "~2.0", Wanted: "v2.0.0", Latest: "v2.1.0"}, s)
+
+	s, err = m.CheckVersion("protoc-gen-fake", "main")
+	require.NoError(t, err)
+	require.Equal(t, VersionStatus{Tool: "protoc-gen-fake", Current: "main", Latest: "v2.1.0"}, s)
+
+	tests := []struct {
+		current string
+		within  string
+		want    string
+	}{
+		{current: "1.2.0", want: "2.1.0"},
+		{current: "v1.2.0", within: "major", want: "v1.3.0"},
+		{current: "1.2.0", within: "minor", want: "1.2.3"},
+		{current: "1.2.0", within: "<2.1", want: "2.0.0"},
+		{current: "2.1.0", want: "2.1.0"},
+		{current: "2.2.0-rc.1", want: "2.2.0-rc.1"},
+	}
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.current+" "+tt.within, func(t *testing.T) {
+			v, err := m.UpgradeVersion("protoc-gen-fake", tt.current, tt.within)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, v)
+		})
+	}
+
+	_, err = m.UpgradeVersion("protoc-gen-fake", "^1.2", "")
+	require.ErrorContains(t, err, "is a constraint")
+}
@@ -0,0 +1,268 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/curioswitch/protog/internal/proto"
+	"golang.org/x/mod/semver"
+	"gopkg.in/yaml.v3"
+)
+
+// npmRuntime is the runtime library that code generated by an npm plugin depends on. The plugin is released with
+// the same version as the runtime.
+type npmRuntime struct {
+	plugin string
+	pkg    string
+	tool   string
+}
+
+var npmRuntimes = []npmRuntime{
+	{
+		plugin: "@bufbuild/protoc-gen-es",
+		pkg:    "@bufbuild/protobuf",
+		tool:   "protoc-gen-es",
+	},
+	{
+		plugin: "@bufbuild/protoc-gen-connect-es",
+		pkg:    "@connectrpc/connect",
+		tool:   "protoc-gen-connect-es",
+	},
+	{
+		// Connect for ECMAScript before it moved to the connectrpc organization.
+		plugin: "@bufbuild/protoc-gen-connect-es",
+		pkg:    "@bufbuild/connect",
+		tool:   "protoc-gen-connect-es",
+	},
+}
+
+// applyPackageJSONVersions sets the versions of npm plugins not set explicitly to match the runtime libraries in
+// the package.json containing dir, preferring the exact versions in its lockfile to its ranges, and warns about
+// explicit versions that do not match.
+func applyPackageJSONVersions(versions Versions, dir string) error {
+	manifestPath, err := proto.FindUp(dir, "package.json")
+	if err != nil || manifestPath == "" {
+		return err
+	}
+	ranges, err := readPackageJSONDependencies(manifestPath)
+	if err != nil {
+		return err
+	}
+	locked, lockPath, err := readNpmLockfile(filepath.Dir(manifestPath))
+	if err != nil {
+		return err
+	}
+
+	for _, r := range npmRuntimes {
+		derived, source := locked[r.pkg], lockPath
+		if derived == "" {
+			derived, source = ranges[r.pkg], manifestPath
+		}
+		if derived == "" {
+			continue
+		}
+		if !isVersionConstraint(derived) {
+			if _, ok := normalizeVersion(derived); !ok {
+				fmt.Fprintf(os.Stderr, "warning: cannot match %s to %s %s in %s\n", r.plugin, r.pkg, derived, source)
+				continue
+			}
+		} else if _, err := parseVersionConstraint(derived); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: cannot match %s to %s %s in %s\n", r.plugin, r.pkg, derived, source)
+			continue
+		}
+
+		explicit := versions[r.tool]
+		if explicit == "" {
+			versions[r.tool] = derived
+			continue
+		}
+		if !versionsAgree(explicit, derived) {
+			fmt.Fprintf(os.Stderr, "warning: %s version %s does not match %s %s in %s\n", r.plugin, explicit, r.pkg, derived, source)
+		}
+	}
+	return nil
+}
+
+func readPackageJSONDependencies(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	deps := map[string]string{}
+	for k, v := range manifest.DevDependencies {
+		deps[k] = v
+	}
+	for k, v := range manifest.Dependencies {
+		deps[k] = v
+	}
+	return deps, nil
+}
+
+// readNpmLockfile returns the installed versions of top-level dependencies from the lockfile of npm, yarn or pnpm
+// in dir or, for workspaces, its parents.
+func readNpmLockfile(dir string) (map[string]string, string, error) {
+	for {
+		for _, lockfile := range []struct {
+			name string
+			read func(path string) (map[string]string, error)
+		}{
+			{name: "package-lock.json", read: readPackageLock},
+			{name: "yarn.lock", read: readYarnLock},
+			{name: "pnpm-lock.yaml", read: readPnpmLock},
+		} {
+			path := filepath.Join(dir, lockfile.name)
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			versions, err := lockfile.read(path)
+			if err != nil {
+				return nil, "", fmt.Errorf("parsing %s: %w", path, err)
+			}
+			return versions, path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, "", nil
+		}
+		dir = parent
+	}
+}
+
+func readPackageLock(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock struct {
+		// lockfileVersion 2 and 3.
+		Packages map[string]struct {
+			Version string `json:"version"`
+		} `json:"packages"`
+		// lockfileVersion 1.
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, err
+	}
+	versions := map[string]string{}
+	for name, dep := range lock.Dependencies {
+		versions[name] = dep.Version
+	}
+	for key, pkg := range lock.Packages {
+		if name := strings.TrimPrefix(key, "node_modules/"); name != key && !strings.Contains(name, "/node_modules/") {
+			versions[name] = pkg.Version
+		}
+	}
+	return versions, nil
+}
+
+// readYarnLock reads a yarn.lock, where each package is a block headed by its comma separated descriptors, e.g.
+// "@bufbuild/protobuf@^1.3.0", "@bufbuild/protobuf@^1.3.1": with an indented version field.
+func readYarnLock(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	versions := map[string]string{}
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			names = names[:0]
+			for _, desc := range strings.Split(strings.TrimSuffix(line, ":"), ",") {
+				desc = strings.Trim(strings.TrimSpace(desc), `"`)
+				// The version separator is the last @, after that of a scope.
+				if i := strings.LastIndexByte(desc, '@'); i > 0 {
+					names = append(names, desc[:i])
+				}
+			}
+			continue
+		}
+		field := strings.TrimSpace(line)
+		var version string
+		if v := strings.TrimPrefix(field, "version "); v != field {
+			version = v
+		} else if v := strings.TrimPrefix(field, "version: "); v != field {
+			// yarn berry.
+			version = v
+		} else {
+			continue
+		}
+		version = strings.Trim(version, `"`)
+		for _, name := range names {
+			// With several copies of a package, prefer the highest as the one most likely hoisted.
+			if cur, ok := versions[name]; !ok || semver.Compare("v"+version, "v"+cur) > 0 {
+				versions[name] = version
+			}
+		}
+	}
+	return versions, scanner.Err()
+}
+
+// readPnpmLock reads the direct dependencies of the root project from a pnpm-lock.yaml.
+func readPnpmLock(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	type deps map[string]yaml.Node
+	var lock struct {
+		Importers map[string]struct {
+			Dependencies    deps `yaml:"dependencies"`
+			DevDependencies deps `yaml:"devDependencies"`
+		} `yaml:"importers"`
+		Dependencies    deps `yaml:"dependencies"`
+		DevDependencies deps `yaml:"devDependencies"`
+	}
+	if err := yaml.Unmarshal(b, &lock); err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	add := func(d deps) {
+		for name, node := range d {
+			// A plain version before lockfile version 6, and afterwards a map with the specifier and version.
+			version := node.Value
+			if node.Kind == yaml.MappingNode {
+				var dep struct {
+					Version string `yaml:"version"`
+				}
+				if err := node.Decode(&dep); err != nil {
+					continue
+				}
+				version = dep.Version
+			}
+			// Peer dependencies are appended in parentheses or after an underscore.
+			if i := strings.IndexAny(version, "(_"); i >= 0 {
+				version = version[:i]
+			}
+			versions[name] = version
+		}
+	}
+	add(lock.DevDependencies)
+	add(lock.Dependencies)
+	if root, ok := lock.Importers["."]; ok {
+		add(root.DevDependencies)
+		add(root.Dependencies)
+	}
+	return versions, nil
+}
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPackageJSONVersions(t *testing.T) {
+	manifest := `{
+  "name": "frontend",
+  "dependencies": {
+    "@bufbuild/protobuf": "^1.3.0",
+    "@connectrpc/connect": "~1.1.2"
+  },
+  "devDependencies": {
+    "@bufbuild/protoc-gen-es": "^1.3.0"
+  }
+}`
+
+	tests := []struct {
+		name     string
+		lockfile string
+		lock     string
+		versions Versions
+		expected Versions
+	}{
+		{
+			name:     "no lockfile",
+			expected: Versions{"protoc-gen-es": "^1.3.0", "protoc-gen-connect-es": "~1.1.2"},
+		},
+		{
+			name:     "package-lock",
+			lockfile: "package-lock.json",
+			lock: `{
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "frontend"},
+    "node_modules/@bufbuild/protobuf": {"version": "1.3.1"},
+    "node_modules/@connectrpc/connect": {"version": "1.1.3"},
+    "node_modules/other/node_modules/@bufbuild/protobuf": {"version": "0.5.0"}
+  }
+}`,
+			expected: Versions{"protoc-gen-es": "1.3.1", "protoc-gen-connect-es": "1.1.3"},
+		},
+		{
+			name:     "package-lock v1",
+			lockfile: "package-lock.json",
+			lock: `{
+  "lockfileVersion": 1,
+  "dependencies": {
+    "@bufbuild/protobuf": {"version": "1.3.1"}
+  }
+}`,
+			expected: Versions{"protoc-gen-es": "1.3.1", "protoc-gen-connect-es": "~1.1.2"},
+		},
+		{
+			name:     "yarn",
+			lockfile: "yarn.lock",
+			lock: `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@bufbuild/protobuf@^1.3.0", "@bufbuild/protobuf@^1.3.1":
+  version "1.3.2"
+  resolved "https://registry.yarnpkg.com/@bufbuild/protobuf/-/protobuf-1.3.2.tgz"
+
+"@connectrpc/connect@~1.1.2":
+  version "1.1.4"
+`,
+			expected: Versions{"protoc-gen-es": "1.3.2", "protoc-gen-connect-es": "1.1.4"},
+		},
+		{
+			name:     "pnpm",
+			lockfile: "pnpm-lock.yaml",
+			lock: `lockfileVersion: '6.0'
+
+importers:
+  .:
+    dependencies:
+      '@bufbuild/protobuf':
+        specifier: ^1.3.0
+        version: 1.3.3
+      '@connectrpc/connect':
+        specifier: ~1.1.2
+        version: 1.1.3(@bufbuild/protobuf@1.3.3)
+`,
+			expected: Versions{"protoc-gen-es": "1.3.3", "protoc-gen-connect-es": "1.1.3"},
+		},
+		{
+			name:     "pnpm v5",
+			lockfile: "pnpm-lock.yaml",
+			lock: `lockfileVersion: 5.4
+
+dependencies:
+  '@bufbuild/protobuf': 1.3.1
+`,
+			expected: Versions{"protoc-gen-es": "1.3.1", "protoc-gen-connect-es": "~1.1.2"},
+		},
+		{
+			name:     "explicit versions kept",
+			lockfile: "package-lock.json",
+			lock:     `{"lockfileVersion": 3, "packages": {"node_modules/@bufbuild/protobuf": {"version": "1.3.1"}}}`,
+			versions: Versions{"protoc-gen-es": "1.2.0", "protoc-gen-connect-es": "^1.1"},
+			expected: Versions{"protoc-gen-es": "1.2.0", "protoc-gen-connect-es": "^1.1"},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "package.json"), []byte(manifest), 0644))
+			if tt.lockfile != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, tt.lockfile), []byte(tt.lock), 0644))
+			}
+			sub := filepath.Join(dir, "src")
+			require.NoError(t, os.Mkdir(sub, 0755))
+
+			versions := Versions{}
+			for k, v := range tt.versions {
+				versions[k] = v
+			}
+			require.NoError(t, applyPackageJSONVersions(versions, sub))
+			require.Equal(t, tt.expected, versions)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// toolSpecsMu guards toolSpecs and registeredPlugins, which plugins can be added to while running.
+var toolSpecsMu sync.RWMutex
+
+// registeredPlugins are the plugins added by RegisterGoPlugin and RegisterNpmPlugin, keyed by their name in protoc
+// flags, e.g. foo for --foo_out.
+var registeredPlugins = map[string]string{}
+
+// lookupTool returns the spec of a tool, built in or registered.
+func lookupTool(name string) (tool, bool) {
+	toolSpecsMu.RLock()
+	defer toolSpecsMu.RUnlock()
+	t, ok := toolSpecs[name]
+	return t, ok
+}
+
+// RegisterGoPlugin registers a plugin that is not built in, built from the Go package cmdPath such as
+// github.com/acme/protoc-gen-foo/cmd/protoc-gen-foo. name is the command of the plugin, e.g. protoc-gen-foo, and
+// the plugin is installed when protoc is run with its flag, e.g. --foo_out. Its version can be set the same as
+// built in plugins.
+func RegisterGoPlugin(name string, cmdPath string) error {
+	return registerPlugin(name, goSpec{name: name, cmdPath: cmdPath})
+}
+
+// RegisterNpmPlugin registers a plugin that is not built in, installed from the npm package pkg such as
+// @acme/protoc-gen-foo. name is the command of the plugin provided by the package, e.g. protoc-gen-foo, and is
+// otherwise the same as for RegisterGoPlugin.
+func RegisterNpmPlugin(name string, pkg string) error {
+	return registerPlugin(name, nodeSpec{
+		name: pkg,
+		path: func(dir, ver string) []string {
+			return []string{filepath.Join(dir, "node_modules", ".bin")}
+		},
+		executables: func(dir string) map[string]string {
+			return map[string]string{name: filepath.Join(dir, "node_modules", ".bin", cmd(name))}
+		},
+	})
+}
+
+func registerPlugin(name string, t tool) error {
+	flag := strings.TrimPrefix(name, "protoc-gen-")
+	if flag == name || flag == "" {
+		return fmt.Errorf("invalid plugin name %s, plugins are named protoc-gen-<name>", name)
+	}
+
+	toolSpecsMu.Lock()
+	defer toolSpecsMu.Unlock()
+	if _, ok := toolSpecs[name]; ok {
+		return fmt.Errorf("tool %s is already registered", name)
+	}
+	toolSpecs[name] = t
+	registeredPlugins[flag] = name
+	return nil
+}
+
+// usedRegisteredPlugins returns the registered plugins whose output flags are in args, sorted.
+func usedRegisteredPlugins(args []string) []string {
+	toolSpecsMu.RLock()
+	defer toolSpecsMu.RUnlock()
+
+	seen := map[string]bool{}
+	var used []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		flag, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !strings.HasSuffix(flag, "_out") {
+			continue
+		}
+		if tool, ok := registeredPlugins[strings.TrimSuffix(flag, "_out")]; ok && !seen[tool] {
+			seen[tool] = true
+			used = append(used, tool)
+		}
+	}
+	sort.Strings(used)
+	return used
+}
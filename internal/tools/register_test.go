@@ -0,0 +1,32 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterPlugin(t *testing.T) {
+	t.Cleanup(func() {
+		for _, name := range []string{"protoc-gen-acme", "protoc-gen-acme-es"} {
+			delete(toolSpecs, name)
+		}
+		delete(registeredPlugins, "acme")
+		delete(registeredPlugins, "acme-es")
+	})
+
+	require.NoError(t, RegisterGoPlugin("protoc-gen-acme", "github.com/acme/protoc-gen-acme"))
+	require.NoError(t, RegisterNpmPlugin("protoc-gen-acme-es", "@acme/protoc-gen-acme-es"))
+
+	require.True(t, IsTool("protoc-gen-acme"))
+	require.Contains(t, ToolNames(), "protoc-gen-acme-es")
+	require.Equal(t, "PROTOC_GEN_ACME_VERSION", VersionVar("protoc-gen-acme"))
+
+	require.EqualError(t, RegisterGoPlugin("protoc-gen-acme", "github.com/other/protoc-gen-acme"), "tool protoc-gen-acme is already registered")
+	require.EqualError(t, RegisterGoPlugin("protoc-gen-go", "github.com/other/protoc-gen-go"), "tool protoc-gen-go is already registered")
+	require.EqualError(t, RegisterGoPlugin("acme", "github.com/acme/acme"), "invalid plugin name acme, plugins are named protoc-gen-<name>")
+
+	require.Equal(t,
+		[]string{"protoc-gen-acme", "protoc-gen-acme-es"},
+		usedRegisteredPlugins([]string{"--acme-es_out=gen", "--go_out=gen", "--acme_out", "gen", "--acme_opt=paths=source_relative", "--acme_out=other", "api.proto"}))
+}
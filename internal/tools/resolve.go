@@ -0,0 +1,304 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// versionSource resolves the versions of a tool.
+type versionSource struct {
+	name string
+	// cacheKey identifies the latest version in the cache of latest versions, including where it is resolved from so
+	// a version resolved from one registry or proxy is not used with another.
+	cacheKey string
+	// latest returns the latest version.
+	latest func() (string, error)
+	// versions lists the available versions for resolving constraints.
+	versions func() ([]string, error)
+}
+
+func (m *ToolManager) specSource(s spec) versionSource {
+	versions := s.versions
+	if versions == nil {
+		versions = func() ([]string, error) {
+			return m.githubReleaseVersions(s.repo, s.tags)
+		}
+	}
+	return versionSource{
+		name:     s.name,
+		cacheKey: s.name + " github=" + githubAPIURL,
+		latest: func() (string, error) {
+			if s.latestVer != nil {
+				return s.latestVer()
+			}
+			return m.latestGitHubVersion(s.repo, s.tags)
+		},
+		versions: versions,
+	}
+}
+
+func (m *ToolManager) goSpecSource(s goSpec) versionSource {
+	proxies, noProxy := m.goProxies()
+	return versionSource{
+		name:     s.name,
+		cacheKey: fmt.Sprintf("%s GOPROXY=%s GONOPROXY=%s github=%s", s.name, proxies, noProxy, githubAPIURL),
+		latest: func() (string, error) {
+			if s.latestVer != nil {
+				return s.latestVer()
+			}
+			v, err := m.latestGoModuleVersion(s.cmdPath)
+			if errors.Is(err, errNoModuleRelease) && s.repo != "" {
+				// Modules without semver tags, or that are private, are resolved from their releases instead.
+				v, err = m.latestGitHubVersion(s.repo, s.tags)
+			}
+			return v, err
+		},
+		versions: func() ([]string, error) {
+			versions, err := m.goModuleVersions(s.cmdPath)
+			if errors.Is(err, errNoModuleRelease) && s.repo != "" {
+				versions, err = m.githubReleaseVersions(s.repo, s.tags)
+			}
+			return versions, err
+		},
+	}
+}
+
+func (m *ToolManager) nodeSpecSource(s nodeSpec) versionSource {
+	return versionSource{
+		name:     s.name,
+		cacheKey: s.distTagPackage() + "@latest registry=" + m.npmRegistry(),
+		latest: func() (string, error) {
+			if s.latestVer != nil {
+				return s.latestVer(), nil
+			}
+			return m.resolveNpmDistTag(s.distTagPackage(), "latest")
+		},
+		versions: func() ([]string, error) {
+			versions, err := m.npmVersions(s.name)
+			if err != nil || s.renamed == nil {
+				return versions, err
+			}
+			renamed, err := m.npmVersions(s.renamed.name)
+			return append(versions, renamed...), err
+		},
+	}
+}
+
+// resolveVersion resolves a configured version, which may be empty for the latest version or a constraint, to a
+// concrete version. Constraints are only resolved once by each ToolManager.
+func (m *ToolManager) resolveVersion(src versionSource, ver string) (string, error) {
+	switch {
+	case ver == "":
+		return m.cachedLatest(src.cacheKey, src.latest)
+	case isVersionConstraint(ver):
+		key := src.cacheKey + "@" + ver
+		m.mu.Lock()
+		v, ok := m.constraints[key]
+		m.mu.Unlock()
+		if ok {
+			return v, nil
+		}
+		v, err := resolveConstraint(src.name, ver, src.versions)
+		if err != nil {
+			return "", err
+		}
+		m.mu.Lock()
+		if m.constraints == nil {
+			m.constraints = map[string]string{}
+		}
+		m.constraints[key] = v
+		m.mu.Unlock()
+		return v, nil
+	default:
+		return ver, nil
+	}
+}
+
+// tool is a spec of a tool that can be resolved and installed.
+type tool interface {
+	source(m *ToolManager) versionSource
+	install(ctx context.Context, m *ToolManager, ver string) (*installation, error)
+}
+
+func (s spec) source(m *ToolManager) versionSource {
+	return m.specSource(s)
+}
+
+func (s spec) install(ctx context.Context, m *ToolManager, ver string) (*installation, error) {
+	return m.fetch(ctx, s, ver)
+}
+
+func (s goSpec) source(m *ToolManager) versionSource {
+	return m.goSpecSource(s)
+}
+
+func (s goSpec) install(ctx context.Context, m *ToolManager, ver string) (*installation, error) {
+	return m.fetchGoSpec(ctx, s, ver)
+}
+
+func (s nodeSpec) source(m *ToolManager) versionSource {
+	return m.nodeSpecSource(s)
+}
+
+func (s nodeSpec) install(ctx context.Context, m *ToolManager, ver string) (*installation, error) {
+	return m.fetchNodeSpec(ctx, s, ver)
+}
+
+// toolSpecs are the tools whose versions can be configured, keyed by the name of their command or, for
+// toolchains, the name used by asdf. Plugins that are not built in are added by registering them.
+var toolSpecs = map[string]tool{
+	"buf":                        bufSpec,
+	"clang-format":               clangFormatSpec,
+	"golang":                     golangSpec,
+	"grpcurl":                    grpcurlSpec,
+	"nodejs":                     nodeJSSpec,
+	"protoc":                     protocSpec,
+	"protoc-gen-connect-es":      protocGenConnectESSpec,
+	"protoc-gen-connect-go":      protocGenConnectGoSpec,
+	"protoc-gen-doc":             protocGenDocSpec,
+	"protoc-gen-docs":            protocGenDocsSpec,
+	"protoc-gen-es":              protocGenESSpec,
+	"protoc-gen-go":              protocGenGoSpec,
+	"protoc-gen-gogofast":        protocGenGogoFastSpec,
+	"protoc-gen-go-grpc":         protocGenGoGRPCSpec,
+	"protoc-gen-golang-deepcopy": protocGenGolangDeepCopySpec,
+	"protoc-gen-golang-jsonshim": protocGenGolangJSONShimSpec,
+	"protoc-gen-grpc":            protocGenGRPCSpec,
+	"protoc-gen-grpc-gateway":    protocGenGRPCGatewaySpec,
+	"protoc-gen-grpc-java":       protocGenGRPCJavaSpec,
+	"protoc-gen-grpc-web":        protocGenGRPCWebSpec,
+	"protoc-gen-jsonschema":      protocGenJSONSchemaSpec,
+	"protoc-gen-ts":              protocGenTSSpec,
+	"protoc-gen-validate":        protocGenValidateSpec,
+	"protolint":                  protolintSpec,
+	"ts-protoc-gen":              improbableTSProtocGenSpec,
+}
+
+// ToolNames returns the names of the tools whose versions can be configured, sorted.
+func ToolNames() []string {
+	toolSpecsMu.RLock()
+	defer toolSpecsMu.RUnlock()
+	names := make([]string, 0, len(toolSpecs))
+	for name := range toolSpecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LatestVersion returns the latest version of a tool, or the latest satisfying constraint if not empty, using the
+// same resolution as when fetching it.
+func (m *ToolManager) LatestVersion(tool string, constraint string) (string, error) {
+	t, ok := lookupTool(tool)
+	if !ok {
+		return "", fmt.Errorf("unknown tool %s", tool)
+	}
+	return m.resolveVersion(t.source(m), constraint)
+}
+
+// Tool is an installed tool.
+type Tool struct {
+	Name    string
+	Version string
+	// Path is the executable of the tool, or empty if it provides several, such as protoc-gen-grpc with the
+	// plugins for each language.
+	Path string
+	// Executables are the known executables of the tool and those it depends on by name, such as node for npm
+	// plugins.
+	Executables map[string]string
+	// Env is the environment to run the tool with, with a PATH containing the tool and those it depends on.
+	Env []string
+}
+
+// toolCommands are the commands of tools not named the same as the tool.
+var toolCommands = map[string]string{
+	"golang": "go",
+	"nodejs": "node",
+}
+
+// CommandTool returns the tool whose command is command, such as golang for go, and whether there is one.
+func CommandTool(command string) (string, bool) {
+	for tool, c := range toolCommands {
+		if c == command {
+			return tool, true
+		}
+	}
+	if _, ok := lookupTool(command); ok {
+		return command, true
+	}
+	return "", false
+}
+
+// Resolve resolves a version of a tool, installing it if needed. An empty version is the one configured for the
+// tool, or the latest if none is. Each version is only installed once by a ToolManager, so Resolve is cheap to call
+// again with the same version.
+func (m *ToolManager) Resolve(ctx context.Context, tool string, version string) (*Tool, error) {
+	t, _, err := m.resolve(ctx, tool, version)
+	return t, err
+}
+
+func (m *ToolManager) resolve(ctx context.Context, tool string, version string) (*Tool, *installation, error) {
+	if version == "" {
+		version = m.config.Versions[tool]
+	}
+	inst, err := m.installTool(ctx, tool, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	command := tool
+	if c, ok := toolCommands[tool]; ok {
+		command = c
+	}
+	executables := inst.allExecutables()
+	exe := inst.commandPath(command)
+	if exe != "" {
+		executables[command] = exe
+	}
+
+	return &Tool{
+		Name:        tool,
+		Version:     inst.version,
+		Path:        exe,
+		Executables: executables,
+		Env:         []string{fmt.Sprintf("PATH=%s", mergePath(inst.fullPath()))},
+	}, inst, nil
+}
+
+// Command returns a command running tool with args, installing it if needed. version is as for Resolve. The
+// command runs in the current environment with the directories of the tool and those it depends on, such as the
+// managed go or node, first in PATH.
+func (m *ToolManager) Command(ctx context.Context, tool string, version string, args ...string) (*exec.Cmd, error) {
+	t, inst, err := m.resolve(ctx, tool, version)
+	if err != nil {
+		return nil, err
+	}
+	if t.Path == "" {
+		return nil, fmt.Errorf("%s does not have a single executable to run", tool)
+	}
+
+	cmd := exec.CommandContext(ctx, t.Path, args...)
+	cmd.Env = withPath(os.Environ(), inst.fullPath())
+	return cmd, nil
+}
+
+// withPath returns env with path prepended to its PATH.
+func withPath(env []string, path []string) []string {
+	res := make([]string, 0, len(env)+1)
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		if strings.EqualFold(k, "PATH") {
+			if v != "" {
+				path = append(path, v)
+			}
+			continue
+		}
+		res = append(res, e)
+	}
+	return append(res, fmt.Sprintf("PATH=%s", mergePath(path)))
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveConcurrent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("archive is a tar.gz")
+	}
+
+	var downloads int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			// Only checking the download.
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		gw := gzip.NewWriter(w)
+		tw := tar.NewWriter(gw)
+		content := []byte("#!/bin/sh\n")
+		_ = tw.WriteHeader(&tar.Header{Name: "protoc-gen-fake", Mode: 0755, Size: int64(len(content))})
+		_, _ = tw.Write(content)
+		_ = tw.Close()
+		_ = gw.Close()
+	}))
+	defer srv.Close()
+
+	toolSpecs["protoc-gen-fake"] = spec{
+		name: "protoc-gen-fake",
+		url: func(ver, os, arch, ext string) string {
+			return srv.URL + "/protoc-gen-fake-" + ver + "." + ext
+		},
+		latestVer: func() (string, error) {
+			return "v1.0.0", nil
+		},
+	}
+	t.Cleanup(func() {
+		delete(toolSpecs, "protoc-gen-fake")
+	})
+
+	m := &ToolManager{dir: t.TempDir()}
+
+	var wg sync.WaitGroup
+	tools := make([]*Tool, 10)
+	errs := make([]error, 10)
+	for i := range tools {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tools[i], errs[i] = m.Resolve(context.Background(), "protoc-gen-fake", "")
+		}(i)
+	}
+	wg.Wait()
+
+	dir := filepath.Join(m.dir, "protoc-gen-fake", "v1.0.0")
+	for i := range tools {
+		require.NoError(t, errs[i])
+		require.Equal(t, "v1.0.0", tools[i].Version)
+		require.Equal(t, filepath.Join(dir, "protoc-gen-fake"), tools[i].Path)
+		require.Equal(t, []string{"PATH=" + dir}, tools[i].Env)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+	// An installed version is reused by later calls.
+	tool, err := m.Resolve(context.Background(), "protoc-gen-fake", "1.0.0")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "protoc-gen-fake"), tool.Path)
+	require.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+	_, err = m.Resolve(context.Background(), "protoc-gen-unknown", "")
+	require.ErrorContains(t, err, "unknown tool protoc-gen-unknown")
+}
+
+func TestWithPath(t *testing.T) {
+	path := []string{"/tools/buf", "/tools/go"}
+	require.Equal(t,
+		[]string{"HOME=/home/user", "PATH=" + mergePath([]string{"/tools/buf", "/tools/go", "/usr/bin"})},
+		withPath([]string{"PATH=/usr/bin", "HOME=/home/user"}, path))
+	require.Equal(t,
+		[]string{"HOME=/home/user", "PATH=" + mergePath(path)},
+		withPath([]string{"HOME=/home/user"}, path))
+}
+
+func TestResolveLatestPerProxy(t *testing.T) {
+	newProxy := func(versions string) string {
+		proxy := t.TempDir()
+		dir := filepath.Join(proxy, "example.com", "plugin", "@v")
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "list"), []byte(versions), 0644))
+		return "file://" + filepath.ToSlash(proxy)
+	}
+	public := newProxy("v1.0.0\nv1.1.0\n")
+	private := newProxy("v1.0.0\n")
+
+	dir := t.TempDir()
+	s := goSpec{name: "protoc-gen-plugin", cmdPath: "example.com/plugin"}
+
+	m := &ToolManager{dir: dir, config: Config{GoEnv: map[string]string{"GOPROXY": public}}}
+	v, err := m.resolveVersion(m.goSpecSource(s), "")
+	require.NoError(t, err)
+	require.Equal(t, "v1.1.0", v)
+
+	// A version cached from another proxy is not used, since this one may not have it.
+	m = &ToolManager{dir: dir, config: Config{GoEnv: map[string]string{"GOPROXY": private}}}
+	v, err = m.resolveVersion(m.goSpecSource(s), "")
+	require.NoError(t, err)
+	require.Equal(t, "v1.0.0", v)
+}
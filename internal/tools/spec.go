@@ -16,9 +16,11 @@ const (
 )
 
 type spec struct {
-	name         string
-	repo         string
-	latestVer    func() (string, error)
+	name      string
+	repo      string
+	latestVer func() (string, error)
+	// versions lists the available versions for resolving constraints, by default the repository's releases.
+	versions     func() ([]string, error)
 	os           func(goos goos) string
 	arch         func(goarch goarch) string
 	ext          func(os string) string
@@ -27,6 +29,8 @@ type spec struct {
 	path         func(dir, ver, os, arch string) []string
 	executables  func(dir, ver, os, arch string) map[string]string
 	goFallbacks  []goFallback
+	// tags is how releases of the spec are tagged when the repository's latest release may be unrelated.
+	tags *tagScheme
 }
 
 type nodeSpec struct {
@@ -35,6 +39,22 @@ type nodeSpec struct {
 	latestVer   func() string
 	path        func(dir, ver string) []string
 	executables func(dir string) map[string]string
+	// renamed is set when newer versions of the package are published under a different name.
+	renamed *npmRename
+}
+
+// distTagPackage returns the package whose dist-tags, such as latest, resolve versions of the spec. When renamed,
+// the new package is the one still being tagged.
+func (s nodeSpec) distTagPackage() string {
+	if s.renamed != nil {
+		return s.renamed.name
+	}
+	return s.name
+}
+
+type npmRename struct {
+	name  string
+	since string
 }
 
 type goSpec struct {
@@ -43,6 +63,8 @@ type goSpec struct {
 	latestVer  func() (string, error)
 	cmdPath    string
 	versionNoV bool
+	// tags is how releases of the spec are tagged when the module proxy does not have them.
+	tags *tagScheme
 }
 
 type goFallback struct {
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 var protocSpec = spec{
@@ -76,10 +78,7 @@ var protocGenGoSpec = spec{
 var protocGenGoGRPCSpec = spec{
 	name: "protoc-gen-go-grpc",
 	repo: "github.com/grpc/grpc-go",
-	latestVer: func() (string, error) {
-		// TODO: Use REST API? To find latest release as they have non-protoc tags in the same repo.
-		return "v1.2.0", nil
-	},
+	tags: &tagScheme{prefix: "cmd/protoc-gen-go-grpc/"},
 	arch: func(goarch) string {
 		// Currently only amd64 is published, so just try it and either Rosetta or qemu may work.
 		// https://github.com/golang/protobuf/issues/1466
@@ -95,13 +94,10 @@ var protocGenGoGRPCSpec = spec{
 		{
 			arch: arm64,
 			spec: goSpec{
-				name: "protoc-gen-go-grpc",
-				repo: "github.com/grpc/grpc-go",
-				latestVer: func() (string, error) {
-					// TODO: Use REST API? To find latest release as they have non-protoc tags in the same repo.
-					return "v1.2.0", nil
-				},
+				name:    "protoc-gen-go-grpc",
+				repo:    "github.com/grpc/grpc-go",
 				cmdPath: "google.golang.org/grpc/cmd/protoc-gen-go-grpc",
+				tags:    &tagScheme{prefix: "cmd/protoc-gen-go-grpc/"},
 			},
 		},
 	},
@@ -296,6 +292,23 @@ var nodeJSSpec = spec{
 			panic(fmt.Sprintf("unsupported arch: %v", goarch))
 		}
 	},
+	versions: func() ([]string, error) {
+		b, err := fetchReleaseIndex("https://nodejs.org/dist/index.json")
+		if err != nil {
+			return nil, err
+		}
+		var releases []struct {
+			Version string `json:"version"`
+		}
+		if err := json.Unmarshal(b, &releases); err != nil {
+			return nil, fmt.Errorf("parsing NodeJS releases: %w", err)
+		}
+		versions := make([]string, 0, len(releases))
+		for _, r := range releases {
+			versions = append(versions, r.Version)
+		}
+		return versions, nil
+	},
 	url: func(ver, os, arch, ext string) string {
 		return fmt.Sprintf("https://nodejs.org/dist/%s/node-%s-%s-%s.%s", ver, ver, os, arch, ext)
 	},
@@ -328,6 +341,7 @@ var protocGenConnectESSpec = nodeSpec{
 	path: func(dir, ver string) []string {
 		return []string{filepath.Join(dir, "node_modules", ".bin")}
 	},
+	renamed: &npmRename{name: "@connectrpc/protoc-gen-connect-es", since: "1.0.0"},
 }
 
 var protocGenConnectWebSpec = nodeSpec{
@@ -372,12 +386,7 @@ var golangSpec = spec{
 	name: "golang",
 	repo: "github.com/golang/go",
 	latestVer: func() (string, error) {
-		resp, err := http.Get("https://go.dev/VERSION?m=text")
-		if err != nil {
-			return "", err
-		}
-		defer resp.Body.Close()
-		ver, err := io.ReadAll(resp.Body)
+		ver, err := fetchReleaseIndex("https://go.dev/VERSION?m=text")
 		if err != nil {
 			return "", err
 		}
@@ -389,6 +398,26 @@ var golangSpec = spec{
 
 		return string(ver), nil
 	},
+	versions: func() ([]string, error) {
+		b, err := fetchReleaseIndex("https://go.dev/dl/?mode=json&include=all")
+		if err != nil {
+			return nil, err
+		}
+		var releases []struct {
+			Version string `json:"version"`
+			Stable  bool   `json:"stable"`
+		}
+		if err := json.Unmarshal(b, &releases); err != nil {
+			return nil, fmt.Errorf("parsing Go releases: %w", err)
+		}
+		var versions []string
+		for _, r := range releases {
+			if r.Stable {
+				versions = append(versions, r.Version)
+			}
+		}
+		return versions, nil
+	},
 	url: func(ver, os, arch, ext string) string {
 		// Strip off leading v
 		ver = ver[1:]
@@ -423,37 +452,32 @@ var protocGenJSONSchemaSpec = goSpec{
 	versionNoV: true,
 }
 
+// istioToolsTags are the tags of istio/tools, which are not prefixed with v so are not module versions on the proxy,
+// and are not published as releases.
+var istioToolsTags = &tagScheme{fromTags: true}
+
 var protocGenDocsSpec = goSpec{
-	name:    "protoc-gen-docs",
-	repo:    "github.com/istio/tools",
-	cmdPath: "istio.io/tools/cmd/protoc-gen-docs",
-	latestVer: func() (string, error) {
-		// TODO: Fetch tags to get version
-		return "1.14.2", nil
-	},
+	name:       "protoc-gen-docs",
+	repo:       "github.com/istio/tools",
+	cmdPath:    "istio.io/tools/cmd/protoc-gen-docs",
 	versionNoV: true,
+	tags:       istioToolsTags,
 }
 
 var protocGenGolangDeepCopySpec = goSpec{
-	name:    "protoc-gen-golang-deepcopy",
-	repo:    "github.com/istio/tools",
-	cmdPath: "istio.io/tools/cmd/protoc-gen-golang-deepcopy",
-	latestVer: func() (string, error) {
-		// TODO: Fetch tags to get version
-		return "1.14.2", nil
-	},
+	name:       "protoc-gen-golang-deepcopy",
+	repo:       "github.com/istio/tools",
+	cmdPath:    "istio.io/tools/cmd/protoc-gen-golang-deepcopy",
 	versionNoV: true,
+	tags:       istioToolsTags,
 }
 
 var protocGenGolangJSONShimSpec = goSpec{
-	name:    "protoc-gen-golang-jsonshim",
-	repo:    "github.com/istio/tools",
-	cmdPath: "istio.io/tools/cmd/protoc-gen-golang-jsonshim",
-	latestVer: func() (string, error) {
-		// TODO: Fetch tags to get version
-		return "1.14.2", nil
-	},
+	name:       "protoc-gen-golang-jsonshim",
+	repo:       "github.com/istio/tools",
+	cmdPath:    "istio.io/tools/cmd/protoc-gen-golang-jsonshim",
 	versionNoV: true,
+	tags:       istioToolsTags,
 }
 
 var protocGenGogoFastSpec = goSpec{
@@ -462,6 +486,53 @@ var protocGenGogoFastSpec = goSpec{
 	cmdPath: "github.com/gogo/protobuf/protoc-gen-gogofast",
 }
 
+var bufSpec = goSpec{
+	name:    "buf",
+	repo:    "github.com/bufbuild/buf",
+	cmdPath: "github.com/bufbuild/buf/cmd/buf",
+}
+
+var grpcurlSpec = goSpec{
+	name:    "grpcurl",
+	repo:    "github.com/fullstorydev/grpcurl",
+	cmdPath: "github.com/fullstorydev/grpcurl/cmd/grpcurl",
+}
+
+var protolintSpec = goSpec{
+	name:    "protolint",
+	repo:    "github.com/yoheimuta/protolint",
+	cmdPath: "github.com/yoheimuta/protolint/cmd/protolint",
+}
+
+// clangFormatSpec is the npm package of clang-format, which bundles prebuilt binaries for each platform.
+var clangFormatSpec = nodeSpec{
+	name: "clang-format",
+	repo: "github.com/angular/clang-format",
+	path: func(dir, ver string) []string {
+		return []string{filepath.Join(dir, "node_modules", ".bin")}
+	},
+	executables: func(dir string) map[string]string {
+		return map[string]string{
+			"clang-format": filepath.Join(dir, "node_modules", ".bin", cmd("clang-format")),
+		}
+	},
+}
+
+var releaseIndexClient = &http.Client{Timeout: 30 * time.Second}
+
+// fetchReleaseIndex fetches the list of published releases of a tool, such as NodeJS or Go.
+func fetchReleaseIndex(u string) ([]byte, error) {
+	resp, err := releaseIndexClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", u, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
 func exe(name string) string {
 	if runtime.GOOS == "windows" {
 		return name + ".exe"
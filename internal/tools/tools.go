@@ -1,45 +1,33 @@
 package tools
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/curioswitch/protog/internal/proto"
 	"github.com/hashicorp/go-getter/v2"
 	"github.com/schollz/progressbar/v3"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 )
 
-type Versions struct {
-	Go                      string
-	NodeJS                  string
-	Protoc                  string
-	ProtocGenConnectES      string
-	ProtocGenConnectGo      string
-	ProtocGenDoc            string
-	ProtocGenDocs           string
-	ProtocGenES             string
-	ProtocGenGo             string
-	ProtocGenGogoFast       string
-	ProtocGenGoGRPC         string
-	ProtocGenGolangDeepCopy string
-	ProtocGenGolangJSONShim string
-	ProtocGenGRPC           string
-	ProtocGenGRPCGateway    string
-	ProtocGenGRPCJava       string
-	ProtocGenGRPCWeb        string
-	ProtocGenJSONSchema     string
-	ProtocGenTS             string
-	ProtocGenValidate       string
-	ProtocTSGen             string
-}
-
 type ProtocConfig struct {
 	ConnectES      bool
 	ConnectGo      bool
@@ -69,196 +57,422 @@ type ProtocConfig struct {
 
 type Config struct {
 	Versions Versions
-	Protoc   ProtocConfig
+	BSR      proto.BSRConfig
+	// GoToolchain is the value of GOTOOLCHAIN, which controls whether the system Go is used.
+	GoToolchain string
+	// GoEnv is the environment for fetching Go modules, such as GOPROXY, used when building plugins and
+	// downloading Go module dependencies.
+	GoEnv map[string]string
+	// NpmRegistry is the registry npm plugins are resolved and installed from.
+	NpmRegistry string
+	// GitHubToken authenticates requests to the GitHub API when resolving releases.
+	GitHubToken string
+	// LatestTTL is how long resolved latest versions are reused, DefaultLatestTTL if zero.
+	LatestTTL time.Duration
+	// Refresh resolves latest versions again regardless of LatestTTL.
+	Refresh bool
+	// GoModVersions sets Go plugin versions not set explicitly to match their runtime libraries in go.mod.
+	GoModVersions bool
+	// GoWork is the value of GOWORK, which selects the workspace go.mod versions are read from.
+	GoWork string
+	// PackageJSONVersions sets npm plugin versions not set explicitly to match their runtime libraries in
+	// package.json.
+	PackageJSONVersions bool
+	// EnvPassthrough are the variables of this process's environment passed to protoc, plugins and plugin builds,
+	// which otherwise only have those protog sets. A trailing * matches variables with the prefix, e.g. LC_*.
+	EnvPassthrough []string
+	// PluginEnv are variables to run protoc with when using a tool, keyed by tool. protoc passes its environment to
+	// all plugins, so they are seen by every plugin in a run using the tool.
+	PluginEnv map[string]map[string]string
+	// Verbose prints the environment protoc and plugin builds are run with.
+	Verbose bool
 }
 
+// ToolManager resolves and installs tools and runs protoc with them. It is safe for concurrent use, and tools are
+// only installed once however many times they are used.
 type ToolManager struct {
 	config Config
 
 	dir string
 
-	path        []string
-	executables map[string]string
+	// mu guards installs and constraints.
+	mu sync.Mutex
+	// installs are the tools installed or being installed, keyed by name and version.
+	installs map[string]*installCall
+	// constraints are resolved version constraints, keyed by tool and constraint.
+	constraints map[string]string
+
+	goMu        sync.Mutex
+	goToolchain *goToolchain
+
+	// latestMu guards latestVersions, latestCalls and latestRefreshed. It is not held while resolving so that
+	// different tools are resolved in parallel.
+	latestMu       sync.Mutex
+	latestVersions map[string]latestVersion
+	// latestCalls are the latest versions being resolved, keyed like latestVersions.
+	latestCalls map[string]*latestCall
+	// latestRefreshed are the keys already resolved by this ToolManager, which are not resolved again when
+	// refreshing.
+	latestRefreshed map[string]bool
+
+	// includesMu serializes fetching imports, which are shared on disk.
+	includesMu sync.Mutex
 }
 
-func NewToolManager(config Config) (*ToolManager, error) {
-	rootDir, err := os.UserCacheDir()
-	if err != nil {
-		return nil, fmt.Errorf("could not determine cache dir: %w", err)
-	}
+// ProtocResult describes a run of protoc by RunProtoc.
+type ProtocResult struct {
+	// Versions are the resolved versions of the tools used.
+	Versions Versions
+	// ToolsDuration is the time spent resolving and installing tools.
+	ToolsDuration time.Duration
+	// IncludesDuration is the time spent fetching imported protos.
+	IncludesDuration time.Duration
+	// ProtocDuration is the time spent running protoc and its plugins.
+	ProtocDuration time.Duration
+}
 
-	return &ToolManager{
-		config: config,
+// installation is a tool installed at a resolved version.
+type installation struct {
+	name    string
+	version string
+	// path are the directories containing the tool's commands.
+	path        []string
+	executables map[string]string
+	// deps are tools needed to run it, such as NodeJS for npm plugins.
+	deps []*installation
 
-		dir:         filepath.Join(rootDir, "org.curioswitch.protog"),
-		executables: map[string]string{},
-	}, nil
+	commandOnce sync.Once
+	command     string
 }
 
-func (m *ToolManager) RunProtoc(args []string, protos []string, includesDir string) error {
-	if err := m.fetch(protocSpec, m.config.Versions.Protoc); err != nil {
-		return err
-	}
-
-	if m.config.Protoc.Go {
-		if err := m.fetch(protocGenGoSpec, m.config.Versions.ProtocGenGo); err != nil {
-			return err
+// commandPath returns the path of the command name provided by the installation, or an empty string if there is
+// none. It is looked up on disk once.
+func (i *installation) commandPath(name string) string {
+	i.commandOnce.Do(func() {
+		if exe, ok := i.executables[name]; ok {
+			i.command = exe
+			return
 		}
-	}
-
-	if m.config.Protoc.GoGRPC {
-		if err := m.fetch(protocGenGoGRPCSpec, m.config.Versions.ProtocGenGoGRPC); err != nil {
-			return err
+		if runtime.GOOS == "windows" {
+			name += ".exe"
 		}
-	}
-
-	if m.config.Protoc.JavaGRPC {
-		if err := m.fetch(protocGenGRPCJavaSpec, m.config.Versions.ProtocGenGRPCJava); err != nil {
-			return err
+		for _, dir := range i.path {
+			p := filepath.Join(dir, name)
+			if info, err := os.Stat(p); err == nil && !info.IsDir() {
+				i.command = p
+				return
+			}
 		}
-	}
+	})
+	return i.command
+}
 
-	if m.config.Protoc.PythonGRPC || m.config.Protoc.RubyGRPC {
-		if err := m.fetch(protocGenGRPCSpec, m.config.Versions.ProtocGenGRPC); err != nil {
-			return err
-		}
+// fullPath returns the directories containing the commands of the installation and its dependencies, its own
+// first.
+func (i *installation) fullPath() []string {
+	path := append([]string{}, i.path...)
+	for _, d := range i.deps {
+		path = append(path, d.fullPath()...)
 	}
+	return path
+}
 
-	// protoc does not source --plugin executables from PATH despite a deceptive error message
-	// https://github.com/protocolbuffers/protobuf/issues/10302
-	if m.config.Protoc.CppGRPC {
-		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_cpp=%s", m.executables["grpc_cpp_plugin"]))
+// allExecutables returns the known executables of the installation and its dependencies, its own taking
+// precedence.
+func (i *installation) allExecutables() map[string]string {
+	res := map[string]string{}
+	for _, d := range i.deps {
+		for k, v := range d.allExecutables() {
+			res[k] = v
+		}
 	}
-	if m.config.Protoc.CSharpGRPC {
-		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_csharp=%s", m.executables["grpc_csharp_plugin"]))
+	for k, v := range i.executables {
+		res[k] = v
 	}
-	if m.config.Protoc.ObjectiveCGRPC {
-		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_objc=%s", m.executables["grpc_objective_c_plugin"]))
+	return res
+}
+
+type installCall struct {
+	done chan struct{}
+	inst *installation
+	err  error
+}
+
+// installOnce installs a tool identified by key, or waits for the same tool being installed concurrently. A failed
+// install is tried again by later calls.
+func (m *ToolManager) installOnce(ctx context.Context, key string, install func() (*installation, error)) (*installation, error) {
+	m.mu.Lock()
+	if c, ok := m.installs[key]; ok {
+		m.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.inst, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
-	if m.config.Protoc.JavascriptGRPC {
-		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_js=%s", m.executables["grpc_node_plugin"]))
+	if m.installs == nil {
+		m.installs = map[string]*installCall{}
 	}
-	if m.config.Protoc.PHPGRPC {
-		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_php=%s", m.executables["grpc_php_plugin"]))
+	c := &installCall{done: make(chan struct{})}
+	m.installs[key] = c
+	m.mu.Unlock()
+
+	c.inst, c.err = install()
+	if c.err != nil {
+		m.mu.Lock()
+		delete(m.installs, key)
+		m.mu.Unlock()
 	}
-	if m.config.Protoc.PythonGRPC {
-		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_python=%s", m.executables["grpc_python_plugin"]))
+	close(c.done)
+	return c.inst, c.err
+}
+
+// toolset collects the tools used by a run of protoc.
+type toolset struct {
+	path        []string
+	executables map[string]string
+	versions    Versions
+}
+
+func (t *toolset) add(tool string, inst *installation) {
+	t.path = append(inst.fullPath(), t.path...)
+	for k, v := range inst.allExecutables() {
+		t.executables[k] = v
 	}
-	if m.config.Protoc.RubyGRPC {
-		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_ruby=%s", m.executables["grpc_ruby_plugin"]))
+	t.versions[tool] = inst.version
+	for _, d := range inst.deps {
+		t.versions[d.name] = d.version
 	}
+}
 
-	if m.config.Protoc.GogoFast {
-		if err := m.fetchGoSpec(protocGenGogoFastSpec, m.config.Versions.ProtocGenGogoFast); err != nil {
-			return err
-		}
+func NewToolManager(config Config) (*ToolManager, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
 	}
 
-	if m.config.Protoc.Doc {
-		if err := m.fetch(protocGenDocSpec, m.config.Versions.ProtocGenDoc); err != nil {
-			return err
-		}
+	// The caller's map is not shared since runs add versions from go.mod and package.json to a copy.
+	versions := make(Versions, len(config.Versions))
+	for k, v := range config.Versions {
+		versions[k] = v
 	}
+	config.Versions = versions
 
-	if m.config.Protoc.Docs {
-		if err := m.fetchGoSpec(protocGenDocsSpec, m.config.Versions.ProtocGenDocs); err != nil {
-			return err
-		}
-	}
+	return &ToolManager{
+		config: config,
 
-	if m.config.Protoc.ConnectES {
-		if err := m.fetchNodeSpec(protocGenConnectESSpec, m.config.Versions.ProtocGenConnectES); err != nil {
-			return err
-		}
-	}
+		dir: dir,
+	}, nil
+}
 
-	if m.config.Protoc.ES {
-		if err := m.fetchNodeSpec(protocGenESSpec, m.config.Versions.ProtocGenES); err != nil {
-			return err
-		}
+// IncludesCacheDir returns the directory imported protos are shared in when not using a per-project directory.
+func IncludesCacheDir() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(dir, "includes"), nil
+}
 
-	if m.config.Protoc.GRPCGateway {
-		if err := m.fetch(protocGenGRPCGatewaySpec, m.config.Versions.ProtocGenGRPCGateway); err != nil {
-			return err
-		}
+func cacheDir() (string, error) {
+	rootDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine cache dir: %w", err)
 	}
+	return filepath.Join(rootDir, "org.curioswitch.protog"), nil
+}
 
-	if m.config.Protoc.GRPCWeb {
-		if err := m.fetch(protocGenGRPCWebSpec, m.config.Versions.ProtocGenGRPCWeb); err != nil {
-			return err
-		}
-	}
+// RunProtoc runs protoc with args and the plugins enabled by p, installing them first if needed. protos are the
+// files being compiled, whose imports are fetched into includesDir if not empty or otherwise the shared cache.
+func (m *ToolManager) RunProtoc(ctx context.Context, p ProtocConfig, args []string, protos []string, includesDir string) (ProtocResult, error) {
+	var res ProtocResult
+	start := time.Now()
 
-	if m.config.Protoc.TS {
-		if err := m.fetchNodeSpec(protocGenTSSpec, m.config.Versions.ProtocGenTS); err != nil {
-			return err
-		}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return res, err
 	}
-
-	if m.config.Protoc.ImprobableTS {
-		if err := m.fetchNodeSpec(improbableTSProtocGenSpec, m.config.Versions.ProtocTSGen); err != nil {
-			return err
-		}
-		args = append(args, fmt.Sprintf("--plugin=protoc-gen-improbable_ts=%s", m.executables["ts-protoc-gen"]))
+	versions, err := m.runVersions(cwd)
+	if err != nil {
+		return res, err
 	}
 
-	if m.config.Protoc.GolangDeepCopy {
-		if err := m.fetchGoSpec(protocGenGolangDeepCopySpec, m.config.Versions.ProtocGenGolangDeepCopy); err != nil {
+	used := &toolset{executables: map[string]string{}, versions: Versions{}}
+	use := func(tool string) error {
+		inst, err := m.installTool(ctx, tool, versions[tool])
+		if err != nil {
 			return err
 		}
+		used.add(tool, inst)
+		return nil
 	}
 
-	if m.config.Protoc.JSONSchema {
-		if err := m.fetchGoSpec(protocGenJSONSchemaSpec, m.config.Versions.ProtocGenJSONSchema); err != nil {
-			return err
+	for _, t := range []struct {
+		tool    string
+		enabled bool
+	}{
+		{tool: "protoc", enabled: true},
+		{tool: "protoc-gen-go", enabled: p.Go},
+		{tool: "protoc-gen-go-grpc", enabled: p.GoGRPC},
+		{tool: "protoc-gen-grpc-java", enabled: p.JavaGRPC},
+		{tool: "protoc-gen-grpc", enabled: p.CppGRPC || p.CSharpGRPC || p.ObjectiveCGRPC || p.JavascriptGRPC || p.PHPGRPC || p.PythonGRPC || p.RubyGRPC},
+		{tool: "protoc-gen-gogofast", enabled: p.GogoFast},
+		{tool: "protoc-gen-doc", enabled: p.Doc},
+		{tool: "protoc-gen-docs", enabled: p.Docs},
+		{tool: "protoc-gen-connect-es", enabled: p.ConnectES},
+		{tool: "protoc-gen-es", enabled: p.ES},
+		{tool: "protoc-gen-grpc-gateway", enabled: p.GRPCGateway},
+		{tool: "protoc-gen-grpc-web", enabled: p.GRPCWeb},
+		{tool: "protoc-gen-ts", enabled: p.TS},
+		{tool: "ts-protoc-gen", enabled: p.ImprobableTS},
+		{tool: "protoc-gen-golang-deepcopy", enabled: p.GolangDeepCopy},
+		{tool: "protoc-gen-jsonschema", enabled: p.JSONSchema},
+		{tool: "protoc-gen-golang-jsonshim", enabled: p.GolangJSONShim},
+		{tool: "protoc-gen-connect-go", enabled: p.ConnectGo},
+		{tool: "protoc-gen-validate", enabled: p.Validate},
+	} {
+		if !t.enabled {
+			continue
 		}
-	}
-
-	if m.config.Protoc.GolangJSONShim {
-		if err := m.fetchGoSpec(protocGenGolangJSONShimSpec, m.config.Versions.ProtocGenGolangJSONShim); err != nil {
-			return err
+		if err := use(t.tool); err != nil {
+			return res, err
 		}
 	}
-
-	if m.config.Protoc.ConnectGo {
-		if err := m.fetchGoSpec(protocGenConnectGoSpec, m.config.Versions.ProtocGenConnectGo); err != nil {
-			return err
+	for _, tool := range usedRegisteredPlugins(args) {
+		if err := use(tool); err != nil {
+			return res, err
 		}
 	}
 
-	if m.config.Protoc.Validate {
-		if err := m.fetchGoSpec(protocGenValidateSpec, m.config.Versions.ProtocGenValidate); err != nil {
-			return err
-		}
+	// protoc does not source --plugin executables from PATH despite a deceptive error message
+	// https://github.com/protocolbuffers/protobuf/issues/10302
+	if p.CppGRPC {
+		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_cpp=%s", used.executables["grpc_cpp_plugin"]))
 	}
-
-	if includesDir == "" {
-		includesDir = filepath.Join("build", "proto-includes")
+	if p.CSharpGRPC {
+		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_csharp=%s", used.executables["grpc_csharp_plugin"]))
 	}
-	if err := os.MkdirAll(includesDir, 0755); err != nil {
-		return err
+	if p.ObjectiveCGRPC {
+		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_objc=%s", used.executables["grpc_objective_c_plugin"]))
 	}
-	if err := proto.FetchIncludes(protos, includesDir); err != nil {
-		return err
+	if p.JavascriptGRPC {
+		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_js=%s", used.executables["grpc_node_plugin"]))
 	}
-	args = append(args, fmt.Sprintf("--proto_path=%s", includesDir))
-	cwd, err := os.Getwd()
-	if err != nil {
-		return err
+	if p.PHPGRPC {
+		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_php=%s", used.executables["grpc_php_plugin"]))
+	}
+	if p.PythonGRPC {
+		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_python=%s", used.executables["grpc_python_plugin"]))
+	}
+	if p.RubyGRPC {
+		args = append(args, fmt.Sprintf("--plugin=protoc-gen-grpc_ruby=%s", used.executables["grpc_ruby_plugin"]))
+	}
+	if p.ImprobableTS {
+		args = append(args, fmt.Sprintf("--plugin=protoc-gen-improbable_ts=%s", used.executables["ts-protoc-gen"]))
+	}
+
+	res.Versions = used.versions
+	res.ToolsDuration = time.Since(start)
+	start = time.Now()
+
+	if includesDir != "" {
+		if err := os.MkdirAll(includesDir, 0755); err != nil {
+			return res, err
+		}
+	} else if info, err := os.Stat(proto.DefaultIncludesDir); err == nil && info.IsDir() {
+		// Includes are shared in the cache but files written to the project's directory, e.g. by protog fetch,
+		// should still be found.
+		args = append(args, fmt.Sprintf("--proto_path=%s", proto.DefaultIncludesDir))
+	}
+	m.includesMu.Lock()
+	extraRoots, err := proto.FetchIncludes(protos, proto.Config{
+		Paths:    proto.ParsePaths(args),
+		Dir:      includesDir,
+		CacheDir: filepath.Join(m.dir, "includes"),
+		GoModDownload: func(mod module.Version) (string, error) {
+			return m.goModDownload(ctx, mod)
+		},
+		NpmInstall: func(packageJSON string) (string, error) {
+			return m.npmInstall(ctx, packageJSON)
+		},
+		BSR: m.config.BSR,
+	})
+	m.includesMu.Unlock()
+	var unresolvedErr *proto.UnresolvedImportsError
+	if errors.As(err, &unresolvedErr) {
+		// protoc may still find imports in ways the scan does not model, so let it decide and only suggest where to
+		// find them in case it fails.
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	} else if err != nil {
+		return res, err
+	}
+	res.IncludesDuration = time.Since(start)
+	if includesDir != "" {
+		args = append(args, fmt.Sprintf("--proto_path=%s", includesDir))
 	}
 	args = append(args, fmt.Sprintf("--proto_path=%s", cwd))
+	for _, root := range extraRoots {
+		args = append(args, fmt.Sprintf("--proto_path=%s", root))
+	}
 
-	cmd := exec.Command(m.executables["protoc"], args...)
+	start = time.Now()
+	cmd := exec.CommandContext(ctx, used.executables["protoc"], args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	cmd.Env = []string{fmt.Sprintf("PATH=%s", mergePath(m.path))}
+	cmd.Env = m.protocEnv(used)
+	m.printEnv("protoc", cmd.Env)
 	if err := cmd.Run(); err != nil {
-		return err
+		return res, err
+	}
+	res.ProtocDuration = time.Since(start)
+
+	return res, nil
+}
+
+// protocEnv returns the environment for running protoc with the used tools, adding the variables configured for each.
+func (m *ToolManager) protocEnv(used *toolset) []string {
+	names := make([]string, 0, len(used.versions))
+	for tool := range used.versions {
+		names = append(names, tool)
+	}
+	sort.Strings(names)
+	extra := make([]map[string]string, 0, len(names))
+	for _, tool := range names {
+		if env := m.config.PluginEnv[tool]; env != nil {
+			extra = append(extra, env)
+		}
+	}
+	return m.subprocessEnv(used.path, extra...)
+}
+
+// runVersions returns the versions of tools for a run in dir, adding those derived from go.mod and package.json
+// when enabled.
+func (m *ToolManager) runVersions(dir string) (Versions, error) {
+	versions := make(Versions, len(m.config.Versions))
+	for k, v := range m.config.Versions {
+		versions[k] = v
+	}
+	if m.config.GoModVersions {
+		if err := applyGoModVersions(versions, dir, m.config.GoWork); err != nil {
+			return nil, fmt.Errorf("reading versions from go.mod: %w", err)
+		}
 	}
+	if m.config.PackageJSONVersions {
+		if err := applyPackageJSONVersions(versions, dir); err != nil {
+			return nil, fmt.Errorf("reading versions from package.json: %w", err)
+		}
+	}
+	return versions, nil
+}
 
-	return nil
+func (m *ToolManager) installTool(ctx context.Context, name string, ver string) (*installation, error) {
+	t, ok := lookupTool(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %s", name)
+	}
+	return t.install(ctx, m, ver)
 }
 
 func determineLatestVersionForGitHubRepo(repo string) (string, error) {
@@ -284,7 +498,7 @@ func determineLatestVersionForGitHubRepo(repo string) (string, error) {
 	}
 }
 
-func (m *ToolManager) fetch(s spec, ver string) error {
+func (m *ToolManager) fetch(ctx context.Context, s spec, ver string) (*installation, error) {
 	var goos goos
 	switch runtime.GOOS {
 	case "darwin":
@@ -294,7 +508,7 @@ func (m *ToolManager) fetch(s spec, ver string) error {
 	case "windows":
 		goos = windows
 	default:
-		return fmt.Errorf("unsupported OS: %s", runtime.GOOS)
+		return nil, fmt.Errorf("unsupported OS: %s", runtime.GOOS)
 	}
 
 	var goarch goarch
@@ -304,30 +518,20 @@ func (m *ToolManager) fetch(s spec, ver string) error {
 	case "arm64":
 		goarch = arm64
 	default:
-		return fmt.Errorf("unsupported architecture: %s", runtime.GOARCH)
+		return nil, fmt.Errorf("unsupported architecture: %s", runtime.GOARCH)
 	}
 
 	for _, f := range s.goFallbacks {
 		if f.arch == goarch {
-			return m.fetchGoSpec(f.spec, ver)
+			return m.fetchGoSpec(ctx, f.spec, ver)
 		}
 	}
 
-	if ver == "" {
-		if s.latestVer != nil {
-			v, err := s.latestVer()
-			if err != nil {
-				return err
-			}
-			ver = v
-		} else {
-			v, err := determineLatestVersionForGitHubRepo(s.repo)
-			if err != nil {
-				return err
-			}
-			ver = v
-		}
+	ver, err := m.resolveVersion(m.specSource(s), ver)
+	if err != nil {
+		return nil, err
 	}
+	resolved := ver
 
 	if ver[0] != 'v' {
 		ver = "v" + ver
@@ -362,152 +566,296 @@ func (m *ToolManager) fetch(s spec, ver string) error {
 	}
 
 	dir := filepath.Join(m.dir, s.name, ver)
-	if s.path != nil {
-		m.path = append(s.path(dir, ver, osStr, archStr), m.path...)
-	} else {
-		m.path = append([]string{dir}, m.path...)
-	}
+	return m.installOnce(ctx, s.name+"@"+ver, func() (*installation, error) {
+		inst := &installation{name: s.name, version: resolved, executables: map[string]string{}}
+		if s.path != nil {
+			inst.path = s.path(dir, ver, osStr, archStr)
+		} else {
+			inst.path = []string{dir}
+		}
+		if s.executables != nil {
+			inst.executables = s.executables(dir, ver, osStr, archStr)
+		}
 
-	if s.executables != nil {
-		for k, v := range s.executables(dir, ver, osStr, archStr) {
-			m.executables[k] = v
+		if _, err := os.Stat(dir); err == nil {
+			return inst, nil
 		}
-	}
 
-	if _, err := os.Stat(dir); err == nil {
-		return nil
-	}
+		url := s.url(ver, osStr, archStr, ext)
 
-	url := s.url(ver, osStr, archStr, ext)
+		client := getter.Client{
+			Getters: []getter.Getter{
+				&getter.HttpGetter{XTerraformGetDisabled: true},
+			},
+		}
 
-	client := getter.Client{
-		Getters: []getter.Getter{
-			&getter.HttpGetter{XTerraformGetDisabled: true},
-		},
-	}
-	ctx := context.Background()
+		if _, err := client.Get(ctx, &getter.Request{
+			Src:              url,
+			Dst:              dir,
+			Umask:            0022,
+			GetMode:          getter.ModeAny,
+			ProgressListener: progress{},
+		}); err != nil {
+			return nil, fmt.Errorf("fetching %s from %s: %w", s.name, url, err)
+		}
+
+		if s.postDownload != nil {
+			if err := s.postDownload(dir, osStr); err != nil {
+				return nil, err
+			}
+		}
 
-	if _, err := client.Get(ctx, &getter.Request{
-		Src:              url,
-		Dst:              dir,
-		Umask:            0022,
-		GetMode:          getter.ModeAny,
-		ProgressListener: progress{},
-	}); err != nil {
-		return fmt.Errorf("fetching %s from %s: %w", s.name, url, err)
+		return inst, nil
+	})
+}
+
+// resolveNodeDistTag resolves a dist-tag of the package of a spec, such as next, to a version.
+func (m *ToolManager) resolveNodeDistTag(s nodeSpec, tag string) (string, error) {
+	pkg := s.distTagPackage()
+	return m.cachedLatest(pkg+"@"+tag, func() (string, error) {
+		return m.resolveNpmDistTag(pkg, tag)
+	})
+}
+
+func (m *ToolManager) fetchNodeSpec(ctx context.Context, s nodeSpec, ver string) (*installation, error) {
+	node, err := m.fetch(ctx, nodeJSSpec, m.config.Versions["nodejs"])
+	if err != nil {
+		return nil, err
 	}
 
-	if s.postDownload != nil {
-		if err := s.postDownload(dir, osStr); err != nil {
-			return err
+	if ver != "" && !isVersionConstraint(ver) && isNpmDistTag(ver) {
+		ver, err = m.resolveNodeDistTag(s, ver)
+		if err != nil {
+			return nil, err
 		}
 	}
+	ver, err = m.resolveVersion(m.nodeSpecSource(s), ver)
+	if err != nil {
+		return nil, err
+	}
+	resolved := ver
 
-	return nil
-}
-
-func (m *ToolManager) fetchNodeSpec(s nodeSpec, ver string) error {
-	if err := m.fetch(nodeJSSpec, m.config.Versions.NodeJS); err != nil {
-		return err
+	if ver[0] != 'v' {
+		ver = "v" + ver
 	}
 
-	if ver == "" {
-		if s.latestVer != nil {
-			ver = s.latestVer()
+	dir := filepath.Join(m.dir, s.name, ver)
+	return m.installOnce(ctx, s.name+"@"+ver, func() (*installation, error) {
+		inst := &installation{name: s.name, version: resolved, deps: []*installation{node}}
+		if s.path != nil {
+			inst.path = s.path(dir, ver)
 		} else {
-			v, err := determineLatestVersionForGitHubRepo(s.repo)
-			if err != nil {
-				return err
-			}
-			ver = v
+			inst.path = []string{dir}
 		}
+		if s.executables != nil {
+			inst.executables = s.executables(dir)
+		}
+
+		if _, err := os.Stat(dir); err == nil {
+			return inst, nil
+		}
+
+		pkg := s.name
+		if s.renamed != nil && semver.Compare(ver, "v"+s.renamed.since) >= 0 {
+			pkg = s.renamed.name
+		}
+
+		cmd := exec.CommandContext(ctx, node.executables["npm"], "install", "--prefix", dir, fmt.Sprintf("%s@%s", pkg, ver))
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+		cmd.Env = m.npmEnv(node.path)
+		m.printEnv("npm install", cmd.Env)
+		if err := cmd.Run(); err != nil {
+			return nil, err
+		}
+
+		return inst, nil
+	})
+}
+
+func (m *ToolManager) fetchGoSpec(ctx context.Context, s goSpec, ver string) (*installation, error) {
+	ver, err := m.resolveVersion(m.goSpecSource(s), ver)
+	if err != nil {
+		return nil, err
 	}
+	resolved := ver
 
-	if ver[0] != 'v' && ver != "next" {
+	if ver[0] != 'v' && !s.versionNoV {
 		ver = "v" + ver
 	}
 
 	dir := filepath.Join(m.dir, s.name, ver)
-	if s.path != nil {
-		m.path = append(s.path(dir, ver), m.path...)
-	} else {
-		m.path = append([]string{dir}, m.path...)
-	}
+	return m.installOnce(ctx, s.name+"@"+ver, func() (*installation, error) {
+		bin := filepath.Join(dir, "bin")
+		inst := &installation{
+			name:        s.name,
+			version:     resolved,
+			path:        []string{bin},
+			executables: map[string]string{s.name: filepath.Join(bin, goExecutableName(s.cmdPath))},
+		}
+
+		if _, err := os.Stat(dir); err == nil {
+			return inst, nil
+		}
+
+		goTool, err := m.resolveGo(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(os.Stderr, "building %s@%s with %s\n", s.cmdPath, ver, goTool)
+
+		env := m.goEnv(fmt.Sprintf("GOPATH=%s", dir), fmt.Sprintf("GOCACHE=%s", filepath.Join(m.dir, "gocache")), "CGO_ENABLED=0")
 
-	if s.executables != nil {
-		for k, v := range s.executables(dir) {
-			m.executables[k] = v
+		cmd := exec.CommandContext(ctx, goTool.path, "install", fmt.Sprintf("%s@%s", s.cmdPath, ver))
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+		cmd.Env = env
+		m.printEnv("go install", cmd.Env)
+		if err := cmd.Run(); err != nil {
+			return nil, err
 		}
+
+		// Don't need this and it's inconvenient to leave around due to not having write permissions.
+		cmd = exec.CommandContext(ctx, goTool.path, "clean", "-modcache")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+		cmd.Env = env
+		if err := cmd.Run(); err != nil {
+			return nil, err
+		}
+
+		return inst, nil
+	})
+}
+
+// goExecutableName returns the name of the executable go install builds for a package, the last element of its
+// path other than a major version suffix.
+func goExecutableName(cmdPath string) string {
+	name := path.Base(cmdPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(cmdPath))
+	}
+	if runtime.GOOS == "windows" {
+		name += ".exe"
 	}
+	return name
+}
 
-	if _, err := os.Stat(dir); err == nil {
-		return nil
+// goModDownload downloads a Go module into a module cache shared by all projects, using the resolved Go
+// toolchain, and returns the directory of its source.
+func (m *ToolManager) goModDownload(ctx context.Context, mod module.Version) (string, error) {
+	goTool, err := m.resolveGo(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	cmd := exec.Command(m.executables["npm"], "install", "--prefix", dir, fmt.Sprintf("%s@%s", s.name, ver))
-	cmd.Stdout = os.Stdout
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, goTool.path, "mod", "download", "-json", mod.String())
+	// Run outside of any module so the project's go.mod, which may require a different toolchain, is not used.
+	cmd.Dir = os.TempDir()
+	cmd.Stdout = &stdout
 	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	cmd.Env = []string{fmt.Sprintf("PATH=%s", mergePath(m.path))}
-	if err := cmd.Run(); err != nil {
-		return err
+	cmd.Env = m.goEnv(
+		fmt.Sprintf("GOPATH=%s", filepath.Join(m.dir, "gopath")),
+		fmt.Sprintf("GOMODCACHE=%s", filepath.Join(m.dir, "gomodcache")),
+		fmt.Sprintf("GOCACHE=%s", filepath.Join(m.dir, "gocache")),
+		"GO111MODULE=on",
+	)
+	m.printEnv("go mod download", cmd.Env)
+	runErr := cmd.Run()
+
+	// Failures to download are reported in the output, which is more informative than the exit status.
+	var res struct {
+		Dir   string
+		Error string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		if runErr != nil {
+			return "", runErr
+		}
+		return "", fmt.Errorf("parsing go mod download output: %w", err)
+	}
+	if res.Error != "" {
+		return "", errors.New(res.Error)
+	}
+	if runErr != nil {
+		return "", runErr
 	}
 
-	return nil
+	return res.Dir, nil
 }
 
-func (m *ToolManager) fetchGoSpec(s goSpec, ver string) error {
-	if err := m.fetch(golangSpec, m.config.Versions.Go); err != nil {
-		return err
+// npmInstall installs the dependencies declared by packageJSON, and its package-lock.json if present, with the
+// managed NodeJS. Installations are cached by the content of the manifests so are shared by projects and
+// checkouts with the same dependencies.
+func (m *ToolManager) npmInstall(ctx context.Context, packageJSON string) (string, error) {
+	node, err := m.fetch(ctx, nodeJSSpec, m.config.Versions["nodejs"])
+	if err != nil {
+		return "", err
 	}
 
-	if ver == "" {
-		if s.latestVer != nil {
-			v, err := s.latestVer()
-			if err != nil {
-				return err
-			}
-			ver = v
-		} else {
-			v, err := determineLatestVersionForGitHubRepo(s.repo)
-			if err != nil {
-				return err
-			}
-			ver = v
-		}
+	manifest, err := os.ReadFile(packageJSON)
+	if err != nil {
+		return "", err
 	}
-
-	if ver[0] != 'v' && !s.versionNoV {
-		ver = "v" + ver
+	lockfile, err := os.ReadFile(filepath.Join(filepath.Dir(packageJSON), "package-lock.json"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
 	}
 
-	dir := filepath.Join(m.dir, s.name, ver)
-	m.path = append([]string{filepath.Join(dir, "bin")}, m.path...)
+	h := sha256.New()
+	h.Write(manifest)
+	h.Write(lockfile)
+	dir := filepath.Join(m.dir, "npm-includes", hex.EncodeToString(h.Sum(nil)))
+	nodeModules := filepath.Join(dir, "node_modules")
 
 	if _, err := os.Stat(dir); err == nil {
-		return nil
+		return nodeModules, nil
 	}
 
-	cmd := exec.Command(m.executables["go"], "install", fmt.Sprintf("%s@%s", s.cmdPath, ver))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	cmd.Env = []string{fmt.Sprintf("GOPATH=%s", dir), fmt.Sprintf("GOCACHE=%s", filepath.Join(m.dir, "gocache")), "CGO_ENABLED=0"}
-	if err := cmd.Run(); err != nil {
-		return err
+	// Install into a temporary directory that is moved into place on success so an interrupted install is not
+	// mistaken for a complete one.
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), "install-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "package.json"), manifest, 0644); err != nil {
+		return "", err
+	}
+	args := []string{"install", "--no-package-lock"}
+	if len(lockfile) > 0 {
+		if err := os.WriteFile(filepath.Join(tmpDir, "package-lock.json"), lockfile, 0644); err != nil {
+			return "", err
+		}
+		args = []string{"ci"}
 	}
+	args = append(args, "--ignore-scripts", "--no-audit", "--no-fund")
 
-	// Don't need this and it's inconvenient to leave around due to not having write permissions.
-	cmd = exec.Command(m.executables["go"], "clean", "-modcache")
+	cmd := exec.CommandContext(ctx, node.executables["npm"], args...)
+	cmd.Dir = tmpDir
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	cmd.Env = []string{fmt.Sprintf("GOPATH=%s", dir), fmt.Sprintf("GOCACHE=%s", filepath.Join(m.dir, "gocache")), "CGO_ENABLED=0"}
+	cmd.Env = m.npmEnv(node.path)
+	m.printEnv("npm install", cmd.Env)
 	if err := cmd.Run(); err != nil {
-		return err
+		return "", err
+	}
+
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return "", err
 	}
 
-	return nil
+	return nodeModules, nil
 }
 
 func mergePath(path []string) string {
@@ -0,0 +1,30 @@
+package tools
+
+import "strings"
+
+// Versions maps names of tools to the versions to use, which may be exact versions, constraints or, for npm
+// plugins, dist-tags. Tools without a version use the latest. Names are those returned by ToolNames, the commands of
+// plugins plus golang, nodejs and protoc as used by asdf.
+type Versions map[string]string
+
+// versionVarExceptions are the environment variables setting versions of tools that do not follow from their names.
+var versionVarExceptions = map[string]string{
+	"golang":              "GO_VERSION",
+	"protoc-gen-gogofast": "PROTOC_GEN_GOGO_FAST_VERSION",
+	"ts-protoc-gen":       "PROTOC_TS_GEN_VERSION",
+}
+
+// VersionVar returns the environment variable setting the version of a tool, e.g. PROTOC_GEN_GO_VERSION for
+// protoc-gen-go.
+func VersionVar(tool string) string {
+	if v, ok := versionVarExceptions[tool]; ok {
+		return v
+	}
+	return strings.ToUpper(strings.ReplaceAll(tool, "-", "_")) + "_VERSION"
+}
+
+// IsTool returns whether protog manages a tool with the name.
+func IsTool(name string) bool {
+	_, ok := lookupTool(name)
+	return ok
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionVar(t *testing.T) {
+	tests := []struct {
+		tool     string
+		expected string
+	}{
+		{tool: "buf", expected: "BUF_VERSION"},
+		{tool: "clang-format", expected: "CLANG_FORMAT_VERSION"},
+		{tool: "golang", expected: "GO_VERSION"},
+		{tool: "grpcurl", expected: "GRPCURL_VERSION"},
+		{tool: "nodejs", expected: "NODEJS_VERSION"},
+		{tool: "protoc", expected: "PROTOC_VERSION"},
+		{tool: "protoc-gen-connect-es", expected: "PROTOC_GEN_CONNECT_ES_VERSION"},
+		{tool: "protoc-gen-connect-go", expected: "PROTOC_GEN_CONNECT_GO_VERSION"},
+		{tool: "protoc-gen-doc", expected: "PROTOC_GEN_DOC_VERSION"},
+		{tool: "protoc-gen-docs", expected: "PROTOC_GEN_DOCS_VERSION"},
+		{tool: "protoc-gen-es", expected: "PROTOC_GEN_ES_VERSION"},
+		{tool: "protoc-gen-go", expected: "PROTOC_GEN_GO_VERSION"},
+		{tool: "protoc-gen-gogofast", expected: "PROTOC_GEN_GOGO_FAST_VERSION"},
+		{tool: "protoc-gen-go-grpc", expected: "PROTOC_GEN_GO_GRPC_VERSION"},
+		{tool: "protoc-gen-golang-deepcopy", expected: "PROTOC_GEN_GOLANG_DEEPCOPY_VERSION"},
+		{tool: "protoc-gen-golang-jsonshim", expected: "PROTOC_GEN_GOLANG_JSONSHIM_VERSION"},
+		{tool: "protoc-gen-grpc", expected: "PROTOC_GEN_GRPC_VERSION"},
+		{tool: "protoc-gen-grpc-gateway", expected: "PROTOC_GEN_GRPC_GATEWAY_VERSION"},
+		{tool: "protoc-gen-grpc-web", expected: "PROTOC_GEN_GRPC_WEB_VERSION"},
+		{tool: "protoc-gen-grpc-java", expected: "PROTOC_GEN_GRPC_JAVA_VERSION"},
+		{tool: "protoc-gen-jsonschema", expected: "PROTOC_GEN_JSONSCHEMA_VERSION"},
+		{tool: "protoc-gen-ts", expected: "PROTOC_GEN_TS_VERSION"},
+		{tool: "protoc-gen-validate", expected: "PROTOC_GEN_VALIDATE_VERSION"},
+		{tool: "protolint", expected: "PROTOLINT_VERSION"},
+		{tool: "ts-protoc-gen", expected: "PROTOC_TS_GEN_VERSION"},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.tool, func(t *testing.T) {
+			require.True(t, IsTool(tt.tool))
+			require.Equal(t, tt.expected, VersionVar(tt.tool))
+		})
+	}
+	require.Len(t, ToolNames(), len(tests))
+}
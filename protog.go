@@ -1,46 +1,213 @@
 package protog
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/curioswitch/protog/internal/cmd"
 	"github.com/curioswitch/protog/internal/tools"
 )
 
-type Versions = tools.Versions
+// ToolVersions maps names of tools to the versions to use, e.g. "protoc-gen-go": "1.31.0". Versions may also be
+// constraints such as ~1.31 or, for npm plugins, dist-tags. Names are those of the plugins' commands plus golang,
+// nodejs and protoc, the same as in .tool-versions, including plugins added with RegisterGoPlugin or
+// RegisterNpmPlugin. Tools without a version use the latest.
+type ToolVersions = tools.Versions
+
+// RegisterGoPlugin adds a plugin that is not built in, built from the Go package cmdPath such as
+// github.com/acme/protoc-gen-foo. name is the command of the plugin, e.g. protoc-gen-foo, and it is installed when
+// protoc is run with its flag, e.g. --foo_out. Its version is set with ToolVersions or an environment variable such
+// as PROTOC_GEN_FOO_VERSION the same as built in plugins.
+func RegisterGoPlugin(name string, cmdPath string) error {
+	return tools.RegisterGoPlugin(name, cmdPath)
+}
+
+// RegisterNpmPlugin adds a plugin that is not built in, installed from the npm package pkg such as
+// @acme/protoc-gen-foo, which provides the command name. It is otherwise the same as RegisterGoPlugin.
+func RegisterNpmPlugin(name string, pkg string) error {
+	return tools.RegisterNpmPlugin(name, pkg)
+}
+
+// Versions sets the versions of the tools protog supported when it was added, and is kept for compatibility.
+// ToolVersions is not limited to these tools.
+type Versions struct {
+	Go                      string
+	NodeJS                  string
+	Protoc                  string
+	ProtocGenConnectES      string
+	ProtocGenConnectGo      string
+	ProtocGenDoc            string
+	ProtocGenDocs           string
+	ProtocGenES             string
+	ProtocGenGo             string
+	ProtocGenGogoFast       string
+	ProtocGenGoGRPC         string
+	ProtocGenGolangDeepCopy string
+	ProtocGenGolangJSONShim string
+	ProtocGenGRPC           string
+	ProtocGenGRPCGateway    string
+	ProtocGenGRPCJava       string
+	ProtocGenGRPCWeb        string
+	ProtocGenJSONSchema     string
+	ProtocGenTS             string
+	ProtocGenValidate       string
+	ProtocTSGen             string
+}
+
+// ToolVersions returns the versions keyed by the names of tools, omitting those not set.
+func (v Versions) ToolVersions() ToolVersions {
+	res := ToolVersions{}
+	for tool, ver := range map[string]string{
+		"golang":                     v.Go,
+		"nodejs":                     v.NodeJS,
+		"protoc":                     v.Protoc,
+		"protoc-gen-connect-es":      v.ProtocGenConnectES,
+		"protoc-gen-connect-go":      v.ProtocGenConnectGo,
+		"protoc-gen-doc":             v.ProtocGenDoc,
+		"protoc-gen-docs":            v.ProtocGenDocs,
+		"protoc-gen-es":              v.ProtocGenES,
+		"protoc-gen-go":              v.ProtocGenGo,
+		"protoc-gen-gogofast":        v.ProtocGenGogoFast,
+		"protoc-gen-go-grpc":         v.ProtocGenGoGRPC,
+		"protoc-gen-golang-deepcopy": v.ProtocGenGolangDeepCopy,
+		"protoc-gen-golang-jsonshim": v.ProtocGenGolangJSONShim,
+		"protoc-gen-grpc":            v.ProtocGenGRPC,
+		"protoc-gen-grpc-gateway":    v.ProtocGenGRPCGateway,
+		"protoc-gen-grpc-java":       v.ProtocGenGRPCJava,
+		"protoc-gen-grpc-web":        v.ProtocGenGRPCWeb,
+		"protoc-gen-jsonschema":      v.ProtocGenJSONSchema,
+		"protoc-gen-ts":              v.ProtocGenTS,
+		"protoc-gen-validate":        v.ProtocGenValidate,
+		"ts-protoc-gen":              v.ProtocTSGen,
+	} {
+		if ver != "" {
+			res[tool] = ver
+		}
+	}
+	return res
+}
 
 type Config struct {
+	// ProtoIncludesDir is a per-project directory to download imported protos into. If empty, they are shared by
+	// all projects in the user cache dir.
 	ProtoIncludesDir string
-	Versions         Versions
+	// ToolVersions sets the versions of tools by name, taking precedence over Versions.
+	ToolVersions ToolVersions
+	// Versions sets the versions of tools with a field per tool. Prefer ToolVersions.
+	Versions Versions
+
+	// BufRegistryURL overrides the Buf Schema Registry that buf.yaml deps are downloaded from.
+	BufRegistryURL string
+	// BufToken authenticates to the Buf Schema Registry.
+	BufToken string
+
+	// GoToolchain controls the Go used to build plugins with the same semantics as GOTOOLCHAIN. By default, a
+	// suitable system Go is used if available.
+	GoToolchain string
+	// GoEnv configures fetching Go modules when building plugins, using the same variables as the go command such
+	// as GOPROXY, GOPRIVATE, GONOSUMDB, GOFLAGS and NETRC.
+	GoEnv map[string]string
+	// NpmRegistry is the registry npm plugins are resolved and installed from, by default the public registry.
+	NpmRegistry string
+	// GitHubToken authenticates requests to the GitHub API when resolving plugin releases, avoiding its low rate
+	// limit for anonymous requests.
+	GitHubToken string
+
+	// LatestTTL is how long resolved latest versions of tools are reused before resolving them again, by default a
+	// day. They are also reused when resolving fails, e.g. when offline.
+	LatestTTL time.Duration
+	// Refresh resolves latest versions again regardless of LatestTTL.
+	Refresh bool
+
+	// GoModVersions sets the versions of Go plugins, when not set in Versions, to match the runtime libraries their
+	// generated code uses in the go.mod or go.work of the current directory, e.g. protoc-gen-go to
+	// google.golang.org/protobuf.
+	GoModVersions bool
+	// PackageJSONVersions sets the versions of npm plugins, when not set in Versions, to match the runtime libraries
+	// their generated code uses in the package.json of the current directory and its lockfile, e.g.
+	// protoc-gen-es to @bufbuild/protobuf.
+	PackageJSONVersions bool
+
+	// EnvPassthrough are the variables of this process's environment passed to protoc, plugins and plugin builds.
+	// By default they only get the variables protog sets, such as a PATH containing the tools used. A trailing *
+	// matches variables with the prefix, e.g. LC_*.
+	EnvPassthrough []string
+	// PluginEnv are variables to run protoc with when using a tool, keyed by tool, e.g. NODE_OPTIONS for
+	// protoc-gen-ts. protoc passes its environment to all plugins, so they are seen by every plugin in a run using
+	// the tool.
+	PluginEnv map[string]map[string]string
+	// Verbose prints the environment protoc and plugin builds are run with.
+	Verbose bool
 }
 
 func Run(args []string, config Config) error {
-	versions := config.Versions
+	env, err := configEnv(config)
+	if err != nil {
+		return err
+	}
+	return cmd.Run(args, env)
+}
+
+func configEnv(config Config) (map[string]string, error) {
 	// round-tripping through env and back is a bit weird but keeps things simplest since we need to
 	// parse args even for programmatic invocation.
 	env := map[string]string{}
 
 	env["PROTO_INCLUDES_DIR"] = config.ProtoIncludesDir
+	env["BUF_REGISTRY_URL"] = config.BufRegistryURL
+	env["BUF_TOKEN"] = config.BufToken
+	env["GOTOOLCHAIN"] = config.GoToolchain
+	env["NPM_CONFIG_REGISTRY"] = config.NpmRegistry
+	env["GITHUB_TOKEN"] = config.GitHubToken
+	if config.LatestTTL != 0 {
+		env["PROTOG_LATEST_TTL"] = config.LatestTTL.String()
+	}
+	if config.Refresh {
+		env["PROTOG_REFRESH"] = "true"
+	}
+	if config.GoModVersions {
+		env["PROTOG_GO_MOD_VERSIONS"] = "true"
+	}
+	if config.PackageJSONVersions {
+		env["PROTOG_PACKAGE_JSON_VERSIONS"] = "true"
+	}
+	for k, v := range config.GoEnv {
+		env[k] = v
+	}
+	env["PROTOG_ENV_PASSTHROUGH"] = strings.Join(config.EnvPassthrough, ",")
+	for tool, vars := range config.PluginEnv {
+		if !tools.IsTool(tool) {
+			return nil, fmt.Errorf("unknown tool %s", tool)
+		}
+		keys := make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = k + "=" + vars[k]
+		}
+		env[tools.PluginEnvVar(tool)] = strings.Join(entries, ",")
+	}
+	if config.Verbose {
+		env["PROTOG_VERBOSE"] = "true"
+	}
 
-	env["GO_VERSION"] = versions.Go
-	env["NODEJS_VERSION"] = versions.NodeJS
-	env["PROTOC_VERSION"] = versions.Protoc
-	env["PROTOC_GEN_DOC_VERSION"] = versions.ProtocGenDoc
-	env["PROTOC_GEN_DOCS_VERSION"] = versions.ProtocGenDocs
-	env["PROTOC_GEN_CONNECT_ES_VERSION"] = versions.ProtocGenConnectES
-	env["PROTOC_GEN_CONNECT_GO_VERSION"] = versions.ProtocGenConnectGo
-	env["PROTOC_GEN_ES_VERSION"] = versions.ProtocGenES
-	env["PROTOC_GEN_GO_VERSION"] = versions.ProtocGenGo
-	env["PROTOC_GEN_GOGO_FAST_VERSION"] = versions.ProtocGenGogoFast
-	env["PROTOC_GEN_GO_GRPC_VERSION"] = versions.ProtocGenGoGRPC
-	env["PROTOC_GEN_GOLANG_DEEPCOPY_VERSION"] = versions.ProtocGenGolangDeepCopy
-	env["PROTOC_GEN_GOLANG_JSONSHIM_VERSION"] = versions.ProtocGenGolangJSONShim
-	env["PROTOC_GEN_GRPC_VERSION"] = versions.ProtocGenGRPC
-	env["PROTOC_GEN_GRPC_GATEWAY_VERSION"] = versions.ProtocGenGRPCGateway
-	env["PROTOC_GEN_GRPC_WEB_VERSION"] = versions.ProtocGenGRPCWeb
-	env["PROTOC_GEN_GRPC_JAVA_VERSION"] = versions.ProtocGenGRPCJava
-	env["PROTOC_GEN_JSONSCHEMA_VERSION"] = versions.ProtocGenJSONSchema
-	env["PROTOC_GEN_TS_VERSION"] = versions.ProtocGenTS
-	env["PROTOC_GEN_VALIDATE_VERSION"] = versions.ProtocGenValidate
-	env["PROTOC_TS_GEN_VERSION"] = versions.ProtocTSGen
+	versions := config.Versions.ToolVersions()
+	for tool, v := range config.ToolVersions {
+		if !tools.IsTool(tool) {
+			return nil, fmt.Errorf("unknown tool %s", tool)
+		}
+		if v != "" {
+			versions[tool] = v
+		}
+	}
+	for tool, v := range versions {
+		env[tools.VersionVar(tool)] = v
+	}
 
-	return cmd.Run(args, env)
+	return env, nil
 }
@@ -208,3 +208,28 @@ func TestRun(t *testing.T) {
 		})
 	}
 }
+
+func TestVersionsToolVersions(t *testing.T) {
+	require.Equal(t, ToolVersions{
+		"golang":        "1.21.3",
+		"protoc":        "24.4",
+		"protoc-gen-go": "v1.31.0",
+		"ts-protoc-gen": "0.15.0",
+	}, Versions{
+		Go:          "1.21.3",
+		Protoc:      "24.4",
+		ProtocGenGo: "v1.31.0",
+		ProtocTSGen: "0.15.0",
+	}.ToolVersions())
+}
+
+func TestConfigEnvRegisteredPlugin(t *testing.T) {
+	require.NoError(t, RegisterGoPlugin("protoc-gen-configenv", "github.com/acme/protoc-gen-configenv"))
+
+	env, err := configEnv(Config{ToolVersions: ToolVersions{"protoc-gen-configenv": "v1.2.0"}})
+	require.NoError(t, err)
+	require.Equal(t, "v1.2.0", env["PROTOC_GEN_CONFIGENV_VERSION"])
+
+	_, err = configEnv(Config{ToolVersions: ToolVersions{"protoc-gen-unregistered": "v1.2.0"}})
+	require.EqualError(t, err, "unknown tool protoc-gen-unregistered")
+}
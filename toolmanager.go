@@ -0,0 +1,86 @@
+package protog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/curioswitch/protog/internal/cmd"
+	"github.com/curioswitch/protog/internal/tools"
+)
+
+// Tool is a tool installed by a ToolManager.
+type Tool = tools.Tool
+
+// ToolManager resolves and installs tools, reusing them across calls. It is safe for concurrent use, with each
+// version of a tool only installed once, so a single ToolManager can be shared by a build.
+type ToolManager struct {
+	m   *tools.ToolManager
+	env map[string]string
+}
+
+// NewToolManager returns a ToolManager using config, along with the versions files in the current directory or
+// its parents the same as Run.
+func NewToolManager(config Config) (*ToolManager, error) {
+	env, err := configEnv(config)
+	if err != nil {
+		return nil, err
+	}
+	tc, err := cmd.ToolsConfig(env)
+	if err != nil {
+		return nil, err
+	}
+	m, err := tools.NewToolManager(tc)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolManager{m: m, env: env}, nil
+}
+
+// Resolve returns a tool such as protoc, protoc-gen-go, golang or nodejs, installing it if needed. An empty
+// version is the one configured for the tool, or the latest if none is. version may also be a constraint such
+// as ~1.31.
+func (t *ToolManager) Resolve(ctx context.Context, tool string, version string) (*Tool, error) {
+	return t.m.Resolve(ctx, tool, version)
+}
+
+// Generate runs protoc like the package-level Generate, with tools from this ToolManager.
+func (t *ToolManager) Generate(ctx context.Context, req GenerateRequest) (*GenerateResult, error) {
+	return generate(ctx, req, t.m, t.env)
+}
+
+// Command returns a command running tool with args, installing it if needed. tool is one that can be resolved, or
+// the go or node command of the managed toolchains, and version is as for Resolve. The command runs in the current
+// environment with the tool and those it depends on first in PATH.
+func (t *ToolManager) Command(ctx context.Context, tool string, version string, args ...string) (*exec.Cmd, error) {
+	name, ok := tools.CommandTool(tool)
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %s", tool)
+	}
+	return t.m.Command(ctx, name, version, args...)
+}
+
+// Exec runs tool with args like Command, connected to the standard input and output of this process.
+func (t *ToolManager) Exec(ctx context.Context, tool string, version string, args ...string) error {
+	cmd, err := t.Command(ctx, tool, version, args...)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Exec runs tool with args, installing it if needed, the same as protog exec. tool may have a version after @,
+// e.g. buf@1.28.1, otherwise the configured version or the latest is used.
+func Exec(ctx context.Context, tool string, args []string, config Config) error {
+	m, err := NewToolManager(config)
+	if err != nil {
+		return err
+	}
+	tool, version, _ := strings.Cut(tool, "@")
+	return m.Exec(ctx, tool, version, args...)
+}